@@ -0,0 +1,82 @@
+package cortana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandsCommand adds a hidden command "commands" which lists the available
+// commands as text or json, so the scripts could enumerate them without parsing
+// the usage
+func CommandsCommand() Option {
+	return func(c *Cortana) {
+		c.AddCommand("commands", c.listCommands, "list the available commands", Hidden())
+	}
+}
+
+// commandEntry is the machine readable form of a command
+type commandEntry struct {
+	Path   string `json:"path"`
+	Brief  string `json:"brief"`
+	Hidden bool   `json:"hidden,omitempty"`
+	Alias  string `json:"alias,omitempty"` // the definition of an alias
+
+	Aliases []string `json:"aliases,omitempty"` // the other names of the command
+}
+
+func (c *Cortana) listCommands() {
+	opts := struct {
+		Format string `cortana:"--format, -f, text, output format, text or json"`
+		Prefix string `cortana:"--prefix, -p, , only list the commands with the prefix"`
+		All    bool   `cortana:"--all, -a, false, list the hidden commands too"`
+	}{}
+	c.Parse(&opts)
+
+	cmds := c.commands.scan(opts.Prefix)
+	sort.Sort(orderedCommands(cmds))
+	var entries []commandEntry
+	for _, cmd := range cmds {
+		if cmd.hidden && !opts.All {
+			continue
+		}
+		brief := cmd.Brief
+		if cmd.Alias {
+			brief = ""
+		}
+		entries = append(entries, commandEntry{Path: cmd.Path, Brief: brief, Hidden: cmd.hidden,
+			Alias: cmd.definition, Aliases: cmd.Aliases})
+	}
+
+	switch opts.Format {
+	case "json":
+		if entries == nil {
+			entries = []commandEntry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			c.fatal(err)
+			return
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	case "text":
+		for _, e := range entries {
+			desc := e.Brief
+			if e.Alias != "" {
+				desc = "alias of " + e.Alias
+			}
+			if e.Hidden {
+				desc += " (hidden)"
+			}
+			path := e.Path
+			if len(e.Aliases) > 0 {
+				path += " (" + strings.Join(e.Aliases, ", ") + ")"
+			}
+			fmt.Fprintf(c.stdout, "%-30s%s\n", path, desc)
+		}
+	default:
+		c.fatal(errors.New("unknown format: " + opts.Format + ", should be text or json"))
+	}
+}
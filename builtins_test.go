@@ -0,0 +1,92 @@
+package cortana
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newCommandsCortana returns a cortana with the commands builtin and some commands
+func newCommandsCortana(stdout, stderr *bytes.Buffer) *Cortana {
+	c := New(ExitOnError(false), WithStdout(stdout), WithStderr(stderr), CommandsCommand())
+	c.AddCommand("db migrate", func() {}, "migrate the db")
+	c.AddCommand("db dump", func() {}, "dump the db", Aliases("backup"))
+	c.AddCommand("serve", func() {}, "serve the api")
+	c.AddHiddenCommand("debug", func() {}, "debug internals")
+	c.Alias("up", "db migrate")
+	return c
+}
+
+func TestCommandsText(t *testing.T) {
+	stdout, stderr := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	c := newCommandsCortana(stdout, stderr)
+	c.Launch("commands")
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	var paths []string
+	for _, line := range lines {
+		paths = append(paths, strings.Fields(line)[0])
+	}
+	// the builtin itself and the hidden commands are not listed
+	if want := []string{"db", "db", "serve", "up"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected %q, got %q", want, paths)
+	}
+	if !strings.Contains(stdout.String(), "db dump (backup)") || !strings.Contains(stdout.String(), "alias of db migrate") {
+		t.Errorf("the names and the alias are not shown:\n%s", stdout.String())
+	}
+}
+
+func TestCommandsJSON(t *testing.T) {
+	stdout, stderr := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	c := newCommandsCortana(stdout, stderr)
+	c.Launch("commands", "--format", "json", "--prefix", "db", "--all")
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	var entries []commandEntry
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	want := []commandEntry{
+		{Path: "db migrate", Brief: "migrate the db"},
+		{Path: "db dump", Brief: "dump the db", Aliases: []string{"backup"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("expected %+v, got %+v", want, entries)
+	}
+
+	// the hidden ones are listed with --all, including the builtin itself
+	stdout.Reset()
+	c.Launch("commands", "-f", "json", "-a")
+	entries = nil
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	hidden := make(map[string]bool)
+	for _, e := range entries {
+		hidden[e.Path] = e.Hidden
+	}
+	if !hidden["debug"] || !hidden["commands"] {
+		t.Errorf("expected the hidden commands, got %+v", entries)
+	}
+
+	// no command is an empty list rather than null
+	stdout.Reset()
+	c.Launch("commands", "-f", "json", "-p", "nothing")
+	if strings.TrimSpace(stdout.String()) != "[]" {
+		t.Errorf("expected an empty list, got %q", stdout.String())
+	}
+}
+
+func TestCommandsUnknownFormat(t *testing.T) {
+	stdout, stderr := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	c := newCommandsCortana(stdout, stderr)
+	c.Launch("commands", "--format", "yaml")
+	if !strings.Contains(stderr.String(), "unknown format: yaml") {
+		t.Errorf("unexpected error %q", stderr.String())
+	}
+}
@@ -0,0 +1,85 @@
+package cortana
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes, it parses a size like 10MB or 1.5GiB with the
+// decimal units KB, MB, GB, TB, PB and the binary units KiB, MiB, GiB, TiB, PiB,
+// case-insensitively. A plain number means bytes
+type ByteSize int64
+
+// the units of the byte sizes, the larger ones first
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"PiB", 1 << 50}, {"PB", 1e15},
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// Set parses the size
+func (b *ByteSize) Set(s string) error {
+	size, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(size)
+	return nil
+}
+
+// String renders the size with the largest unit which divides it exactly, like 4MB
+func (b ByteSize) String() string {
+	if b == 0 {
+		return "0B"
+	}
+	for _, unit := range byteUnits {
+		if int64(b)%unit.size == 0 {
+			return strconv.FormatInt(int64(b)/unit.size, 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+// parseByteSize parses a size like 10MB or 1.5GiB, a negative size is rejected
+func parseByteSize(s string) (int64, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && (s[i] == '.' || s[i] == '-' || s[i] == '+' || s[i] >= '0' && s[i] <= '9') {
+		i++
+	}
+	number, name := s[:i], strings.TrimSpace(s[i:])
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, errors.New("invalid size " + strconv.Quote(orig))
+	}
+	if n < 0 {
+		return 0, errors.New("size " + strconv.Quote(orig) + " is negative")
+	}
+	size := int64(1)
+	if name != "" {
+		size = 0
+		for _, unit := range byteUnits {
+			if strings.EqualFold(name, unit.name) {
+				size = unit.size
+				break
+			}
+		}
+		if size == 0 {
+			return 0, errors.New("unknown unit " + strconv.Quote(name) + " in size " + strconv.Quote(orig))
+		}
+	}
+	n *= float64(size)
+	if n > math.MaxInt64 || n < math.MinInt64 {
+		return 0, errors.New("size " + strconv.Quote(orig) + " overflows")
+	}
+	return int64(n), nil
+}
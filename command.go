@@ -1,6 +1,9 @@
 package cortana
 
 import (
+	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/google/btree"
@@ -12,7 +15,157 @@ type Command struct {
 	Proc  func()
 	Brief string
 	Alias bool
-	order int // the order is the sequence of invoking add command
+	Group string // the section of the command in the usage, like "Debugging"
+	order int    // the order is the sequence of invoking add command
+
+	Aliases []string // the other names of the command, like "rm" for "remove"
+
+	Annotations []string // the needs checked by the preflights, like "network"
+
+	strict     bool         // unknown sub commands are errors instead of positional args
+	strictArgs bool         // the positional args are never sub commands
+	hidden     bool         // the command is not listed in the usage
+	definition string       // the definition of an alias
+	synopsis   string       // overrides the generated synopsis line of the usage
+	examples   []string     // the example invocations in the usage
+	footer     string       // the text at the end of the usage
+	spec       *CommandSpec // the entry of the spec the command is loaded from
+	options    reflect.Type // the type of the options struct bound at registration
+	run        func() error // the handler returning its error, Proc reports the error itself
+	beforeRun  []func(cmd *Command, args []string) error
+	afterRun   []func(cmd *Command, err error)
+}
+
+// exec runs the command and returns the error of the handler
+func (cmd *Command) exec() error {
+	if cmd.run == nil {
+		cmd.Proc()
+		return nil
+	}
+	return cmd.run()
+}
+
+// IsHidden reports if the command is hidden from the usage
+func (cmd *Command) IsHidden() bool {
+	return cmd.hidden
+}
+
+// CommandOption customizes a command when adding it
+type CommandOption func(cmd *Command)
+
+// StrictSubcommands treats the first positional arg as a sub command if the command
+// has children, an unknown one is reported as an error instead of being passed to
+// the command as an argument
+func StrictSubcommands() CommandOption {
+	return func(cmd *Command) {
+		cmd.strict = true
+	}
+}
+
+// StrictArgs treats all the args after the command as its own, they are never
+// searched as sub commands. By default the deepest command wins, so with "say" and
+// "say hello" registered, "say hello" runs "say hello" unless "say" is strict.
+// A leading "--" stops the search for a single invocation: "say -- hello"
+func StrictArgs() CommandOption {
+	return func(cmd *Command) {
+		cmd.strictArgs = true
+	}
+}
+
+// Hidden hides the command from the usage, it can still be executed
+func Hidden() CommandOption {
+	return func(cmd *Command) {
+		cmd.hidden = true
+	}
+}
+
+// WithSynopsis overrides the generated synopsis line of the usage, which is useful
+// for the commands with several forms like "cp <src>... <dst> | cp --from-manifest <file>"
+func WithSynopsis(synopsis string) CommandOption {
+	return func(cmd *Command) {
+		cmd.synopsis = synopsis
+	}
+}
+
+// WithExamples lists the example invocations in the usage of the command, like
+// "mytool get pods -o json"
+func WithExamples(examples ...string) CommandOption {
+	return func(cmd *Command) {
+		cmd.examples = append(cmd.examples, examples...)
+	}
+}
+
+// WithFooter ends the usage of the command with the text, like a link to the docs
+func WithFooter(footer string) CommandOption {
+	return func(cmd *Command) {
+		cmd.footer = footer
+	}
+}
+
+// WithGroup lists the command under the section of the group in the usage, the
+// groups are in the order of their first commands
+func WithGroup(group string) CommandOption {
+	return func(cmd *Command) {
+		cmd.Group = group
+	}
+}
+
+// Aliases gives the command other names, which run the command the same way as its
+// own name. An alias replaces the last word of the path, so "rm" of "remote remove"
+// is typed as "remote rm", and a command of the same path wins over an alias
+func Aliases(names ...string) CommandOption {
+	return func(cmd *Command) {
+		cmd.Aliases = append(cmd.Aliases, names...)
+	}
+}
+
+// aliasPath returns the path of the alias name of the command
+func aliasPath(path, name string) string {
+	return strings.TrimSpace(parentPath(path) + " " + name)
+}
+
+// parentPath returns the path without the last word, empty for the top commands
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, " "); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// WithFlags binds the options struct of the command at registration, so the usage
+// can be rendered without executing the command. v is only used for its type
+func WithFlags(v interface{}) CommandOption {
+	return func(cmd *Command) {
+		rt := reflect.TypeOf(v)
+		for rt != nil && rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		cmd.options = rt
+	}
+}
+
+// UnknownCommandError reports a command which can not be found
+type UnknownCommandError struct {
+	Name        string   // the unknown command
+	Parent      string   // the path of the parent command, empty if it is the root
+	Suggestions []string // the similar commands
+}
+
+func (e *UnknownCommandError) Error() string {
+	msg := "unknown command: " + e.Name
+	if e.Parent != "" {
+		msg = fmt.Sprintf("unknown subcommand %q for %q", e.Name, e.Parent)
+	} else if len(e.Suggestions) > 0 {
+		msg = fmt.Sprintf("unknown command %q", e.Name)
+	}
+	if len(e.Suggestions) == 0 {
+		return msg
+	}
+	quoted := make([]string, len(e.Suggestions))
+	for i, s := range e.Suggestions {
+		quoted[i] = strconv.Quote(s)
+	}
+	return msg + " — did you mean " + strings.Join(quoted, " or ") + "?"
 }
 
 type command Command
@@ -45,6 +198,52 @@ func (c commands) get(path string) *command {
 	return nil
 }
 
+// children returns the immediate sub commands of path, the name is
+// the next segment of the path, cmd is nil if there is only a deeper command
+func (c commands) children(path string) []childCommand {
+	prefix := path
+	if prefix != "" {
+		prefix += " "
+	}
+	var children []childCommand
+	idx := make(map[string]int)
+	for _, cmd := range c.scan(prefix) {
+		name := strings.TrimPrefix(cmd.Path, prefix)
+		if i := strings.Index(name, " "); i >= 0 {
+			name = name[:i]
+		}
+		if name == "" {
+			continue
+		}
+		i, ok := idx[name]
+		if !ok {
+			idx[name] = len(children)
+			children = append(children, childCommand{name: name})
+			i = len(children) - 1
+		}
+		if cmd.Path == prefix+name {
+			children[i].cmd = cmd
+		}
+	}
+	return children
+}
+
+type childCommand struct {
+	name string
+	cmd  *command
+}
+
+// visibleCommands filters out the hidden commands
+func visibleCommands(cmds []*command) []*command {
+	var visible []*command
+	for _, cmd := range cmds {
+		if !cmd.hidden {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
+
 // orderedCommands keep the order of adding a command
 type orderedCommands []*command
 
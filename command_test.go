@@ -0,0 +1,164 @@
+package cortana
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandAmbiguity(t *testing.T) {
+	cases := []struct {
+		name   string
+		strict bool
+		args   []string
+		path   string
+		rest   []string
+	}{
+		{"deepest wins", false, []string{"say", "hello", "world"}, "say hello", []string{"world"}},
+		{"deepest exact", false, []string{"say", "hello"}, "say hello", []string{}},
+		{"quoted by --", false, []string{"say", "--", "hello"}, "say", []string{"--", "hello"}},
+		{"prefix backtracks", false, []string{"say", "hi", "cortana"}, "say", []string{"hi", "cortana"}},
+		{"prefix of a deeper command", false, []string{"say", "hey", "world"}, "say", []string{"hey", "world"}},
+		{"strict args", true, []string{"say", "hello"}, "say", []string{"hello"}},
+		{"strict args with --", true, []string{"say", "--", "hello"}, "say", []string{"--", "hello"}},
+		{"strict args deeper", true, []string{"say", "hello", "world"}, "say", []string{"hello", "world"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := New(ExitOnError(false))
+			var opts []CommandOption
+			if tc.strict {
+				opts = append(opts, StrictArgs())
+			}
+			c.AddCommand("say", func() {}, "say anything", opts...)
+			c.AddCommand("say hello", func() {}, "say hello")
+			c.AddCommand("say hey cortana", func() {}, "say hey to cortana")
+
+			cmd := c.SearchCommand(tc.args)
+			if cmd == nil {
+				t.Fatalf("no command is found for %q", tc.args)
+			}
+			if cmd.Path != tc.path {
+				t.Errorf("expected %q, got %q", tc.path, cmd.Path)
+			}
+			args := append([]string{}, c.Args()...)
+			if !reflect.DeepEqual(args, tc.rest) {
+				t.Errorf("expected the args %q, got %q", tc.rest, args)
+			}
+		})
+	}
+}
+
+func TestHiddenCommand(t *testing.T) {
+	stdout := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(stdout), WithStderr(io.Discard))
+	var ran []string
+	for _, path := range []string{"debug", "debug dump-state", "__migrate", "migrate"} {
+		path := path
+		var opts []CommandOption
+		if path != "migrate" {
+			opts = append(opts, Hidden())
+		}
+		c.AddCommand(path, func() { ran = append(ran, path) }, "run "+path, opts...)
+	}
+
+	// reachable
+	for _, args := range [][]string{{"debug", "dump-state"}, {"__migrate"}, {"migrate"}} {
+		if err := c.LaunchE(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"debug dump-state", "__migrate", "migrate"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("expected %q to run, got %q", want, ran)
+	}
+
+	// invisible
+	stdout.Reset()
+	if err := c.LaunchE(); err != nil {
+		t.Fatal(err)
+	}
+	usage := stdout.String()
+	if !strings.Contains(usage, "migrate") {
+		t.Errorf("expected migrate in the usage %q", usage)
+	}
+	for _, name := range []string{"debug", "dump-state", "__migrate"} {
+		if strings.Contains(usage, name) {
+			t.Errorf("expected %s absent from the usage %q", name, usage)
+		}
+	}
+	paths := func(cmds []*Command) []string {
+		var paths []string
+		for _, cmd := range cmds {
+			paths = append(paths, cmd.Path)
+		}
+		return paths
+	}
+	if got := paths(c.Commands()); !reflect.DeepEqual(got, []string{"migrate"}) {
+		t.Errorf("expected only migrate in Commands(), got %q", got)
+	}
+	if got := paths(c.AllCommands()); len(got) != 4 {
+		t.Errorf("expected all the commands in AllCommands(), got %q", got)
+	}
+	for _, prefix := range []string{"", "debug", "__"} {
+		for _, path := range paths(c.Complete(prefix)) {
+			if path != "migrate" {
+				t.Errorf("expected %s absent from the completion of %q", path, prefix)
+			}
+		}
+	}
+	if got := paths(c.Complete("m")); !reflect.DeepEqual(got, []string{"migrate"}) {
+		t.Errorf("expected migrate completed, got %q", got)
+	}
+}
+
+type codedError struct{ code int }
+
+func (e codedError) Error() string { return "coded" }
+func (e codedError) ExitCode() int { return e.code }
+
+func TestFatalExitCodes(t *testing.T) {
+	usage := errors.New("usage")
+	cases := []struct {
+		name     string
+		err      error
+		classify func(err error) int
+		code     int
+	}{
+		{"default", errors.New("failed"), nil, -1},
+		{"exit coder", fmt.Errorf("wrapped: %w", codedError{3}), nil, 3},
+		{"classified", usage, func(err error) int {
+			if errors.Is(err, usage) {
+				return 2
+			}
+			return 0
+		}, 2},
+		{"classifier falls back", codedError{4}, func(err error) int { return 0 }, 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var reported error
+			code := 0
+			c := New(WithExit(func(c int) { code = c }), WithExitCodes(tc.classify), OnError(func(err error) {
+				reported = err
+			}))
+			c.AddCommand("run", func() { c.Fatal(tc.err) }, "run")
+			c.Launch("run")
+			if reported != tc.err || code != tc.code {
+				t.Errorf("expected %v with %d, got %v with %d", tc.err, tc.code, reported, code)
+			}
+		})
+	}
+
+	// the usage exits with 0 by the injected exit
+	code := -1
+	c := New(WithStdout(io.Discard), WithExit(func(c int) { code = c }))
+	c.AddCommand("run", func() { c.Parse(&struct{}{}) }, "run")
+	c.Launch("run", "--help")
+	if code != 0 {
+		t.Errorf("expected the usage to exit with 0, got %d", code)
+	}
+}
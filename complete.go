@@ -0,0 +1,160 @@
+package cortana
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// predefinedFlags returns the flags defined by the options like HelpFlag, ConfFlag
+// and ProfileFlag, they are listed and completed like the flags of the struct. The
+// help flag is given since it may be overridden for a parse
+func (c *Cortana) predefinedFlags(help longshort) []*flag {
+	var flags []*flag
+	if help.short != "" || help.long != "" {
+		flags = append(flags, &flag{
+			long:        help.long,
+			short:       help.short,
+			description: help.desc,
+			rv:          reflect.ValueOf(false),
+		})
+	}
+	if c.predefined.cfg.short != "" || c.predefined.cfg.long != "" {
+		path := ""
+		for i, cfg := range c.configs {
+			if i == len(c.configs)-1 {
+				path += cfg.path
+			} else {
+				path += cfg.path + ","
+			}
+		}
+		flags = append(flags, &flag{
+			long:         c.predefined.cfg.long,
+			short:        c.predefined.cfg.short,
+			description:  c.predefined.cfg.desc,
+			required:     true,
+			defaultValue: path,
+			complete:     completeFiles,
+		})
+	}
+	if c.predefined.profile.short != "" || c.predefined.profile.long != "" {
+		flags = append(flags, &flag{
+			long:        c.predefined.profile.long,
+			short:       c.predefined.profile.short,
+			description: c.predefined.profile.desc,
+			rv:          reflect.ValueOf(""),
+			complete:    c.completeProfiles,
+		})
+	}
+	if c.predefined.output.short != "" || c.predefined.output.long != "" {
+		flags = append(flags, &flag{
+			long:         c.predefined.output.long,
+			short:        c.predefined.output.short,
+			description:  c.predefined.output.desc,
+			defaultValue: outputFormats[0],
+			rv:           reflect.ValueOf(""),
+			complete:     completeOutputFormats,
+		})
+	}
+	if c.predefined.dump.short != "" || c.predefined.dump.long != "" {
+		flags = append(flags, &flag{
+			long:        c.predefined.dump.long,
+			short:       c.predefined.dump.short,
+			description: c.predefined.dump.desc,
+			rv:          reflect.ValueOf(false),
+		})
+	}
+	if c.predefined.explain.short != "" || c.predefined.explain.long != "" {
+		flags = append(flags, &flag{
+			long:        c.predefined.explain.long,
+			short:       c.predefined.explain.short,
+			description: c.predefined.explain.desc,
+			rv:          reflect.ValueOf(false),
+		})
+	}
+	return flags
+}
+
+// CompleteFlag returns the candidate values with the prefix for the flag named
+// by its long or short name, the flags of the last parsed struct and the predefined
+// flags are searched. It returns nil if the flag has no completion
+func (c *Cortana) CompleteFlag(name, prefix string) []string {
+	flags := append(c.predefinedFlags(c.predefined.help), c.parsing.flags...)
+	for _, f := range flags {
+		if name == "" || (name != f.long && name != f.short) {
+			continue
+		}
+		if f.complete == nil || f.hidden {
+			return nil
+		}
+		return f.complete(prefix)
+	}
+	return nil
+}
+
+// Completer is implemented by the option structs which complete the values of their
+// fields dynamically, like the names fetched from a server. field is the name of the
+// go field, a nested struct completes its own fields. The candidates of the complete
+// or choices tag, or the files for a File, are offered if it returns nil
+type Completer interface {
+	Complete(field, prefix string) []string
+}
+
+// completeField completes the field by the Completer and then by the fallback
+func completeField(completer Completer, field string, fallback func(prefix string) []string) func(prefix string) []string {
+	return func(prefix string) []string {
+		if candidates := completer.Complete(field, prefix); candidates != nil {
+			return candidates
+		}
+		if fallback != nil {
+			return fallback(prefix)
+		}
+		return nil
+	}
+}
+
+// completeFiles lists the paths with the prefix, the directories end with a separator
+func completeFiles(prefix string) []string {
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil
+	}
+	for i, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.IsDir() {
+			matches[i] = m + string(filepath.Separator)
+		}
+	}
+	return matches
+}
+
+// completeProfiles lists the names of the profiles in the configurations
+func (c *Cortana) completeProfiles(prefix string) []string {
+	var names []string
+	for _, cfg := range c.configs {
+		if cfg.path == "" || cfg.unmarshaler == nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(cfg.path)
+		if err != nil {
+			continue
+		}
+		m := make(map[string]interface{})
+		if err := cfg.unmarshaler.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		profiles, ok, err := lookupConfigKey(m, "profiles")
+		if err != nil || !ok {
+			continue
+		}
+		for _, name := range tableKeys(profiles) {
+			if strings.HasPrefix(name, prefix) && !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
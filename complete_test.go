@@ -0,0 +1,51 @@
+package cortana
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+type regionOptions struct {
+	Zone string `cortana:"--zone, -, , zone" choices:"a,b"`
+}
+
+// Complete offers the zones with the prefix, the choices are offered without one
+func (o *regionOptions) Complete(field, prefix string) []string {
+	if field != "Zone" || prefix == "" {
+		return nil
+	}
+	return []string{prefix + "1", prefix + "2"}
+}
+
+type deployOptions struct {
+	Region regionOptions `cortana:"prefix=region-"`
+	Host   string        `cortana:"--host, -h, , host"`
+	Level  string        `cortana:"--level, -l, , level" complete:"debug,info"`
+}
+
+func (o *deployOptions) Complete(field, prefix string) []string {
+	if field == "Host" {
+		return []string{"localhost"}
+	}
+	return nil
+}
+
+func TestCompleter(t *testing.T) {
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	c.Parse(&deployOptions{}, WithArgs([]string{}))
+	cases := []struct {
+		flag, prefix string
+		want         []string
+	}{
+		{"--host", "", []string{"localhost"}},
+		{"--region-zone", "z", []string{"z1", "z2"}},
+		{"--region-zone", "", []string{"a", "b"}},
+		{"-l", "d", []string{"debug"}},
+	}
+	for _, tc := range cases {
+		if got := c.CompleteFlag(tc.flag, tc.prefix); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %q: expected %q, got %q", tc.flag, tc.prefix, tc.want, got)
+		}
+	}
+}
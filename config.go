@@ -1,5 +1,14 @@
 package cortana
 
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type longshort struct {
 	long  string
 	short string
@@ -11,3 +20,257 @@ type config struct {
 	unmarshaler  Unmarshaler
 	requireExist bool
 }
+
+// applyConfigKeys sets the flags bound to a nested key with the "config" tag, the
+// data is decoded to a generic map, so it works regardless of the format
+func (c *Cortana) applyConfigKeys(cfg *config, data []byte) error {
+	var flags []*flag
+	for _, f := range c.parsing.flags {
+		if f.configKey != "" {
+			flags = append(flags, f)
+		}
+	}
+	for _, nf := range c.parsing.nonflags {
+		if nf.configKey != "" {
+			flags = append(flags, (*flag)(nf))
+		}
+	}
+	if len(flags) == 0 {
+		return nil
+	}
+
+	m := make(map[string]interface{})
+	if err := unmarshalContext(c.parsing.ctx, cfg.unmarshaler, data, &m); err != nil {
+		return err
+	}
+	for _, f := range flags {
+		v, ok, err := lookupConfigKey(m, f.configKey)
+		if err != nil {
+			return fmt.Errorf("%s: %v", cfg.path, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := applyConfigValue(f, v); err != nil {
+			return fmt.Errorf("%s: config key %s: %v", cfg.path, f.configKey, err)
+		}
+	}
+	return nil
+}
+
+// lookupConfigKey finds the value of a dotted key like "server.port"
+func lookupConfigKey(m map[string]interface{}, key string) (interface{}, bool, error) {
+	var v interface{} = m
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		var ok bool
+		switch table := v.(type) {
+		case map[string]interface{}:
+			v, ok = table[part]
+		case map[interface{}]interface{}: // yaml decodes maps with interface keys
+			v, ok = table[part]
+		default:
+			return nil, false, fmt.Errorf("config key %s: %s is a %T, not a table",
+				key, strings.Join(parts[:i], "."), v)
+		}
+		if !ok || v == nil {
+			return nil, false, nil
+		}
+	}
+	return v, true, nil
+}
+
+// applyConfigValue sets the generic value decoded from the config to the flag
+func applyConfigValue(f *flag, v interface{}) error {
+	// the config is structured already, so a json flag takes the value as is
+	if _, ok := v.(string); f.format == "json" && !ok {
+		data, err := json.Marshal(stringKeys(v))
+		if err != nil {
+			return err
+		}
+		e := reflect.New(f.rv.Type())
+		if err := json.Unmarshal(data, e.Interface()); err != nil {
+			return err
+		}
+		f.rv.Set(e.Elem())
+		return nil
+	}
+	if values, ok := v.([]interface{}); ok {
+		if !isList(f.rv) {
+			return fmt.Errorf("expected a single value for %s, got a list", f.rv.Type())
+		}
+		f.resetList()
+		for _, e := range values {
+			s, err := configScalar(e)
+			if err != nil {
+				return err
+			}
+			if err := applyValue(f, f.rv, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := configScalar(v)
+	if err != nil {
+		return err
+	}
+	if isList(f.rv) {
+		f.resetList()
+	}
+	return applyValue(f, f.rv, s)
+}
+
+// stringKeys converts the maps with interface keys decoded by yaml to the ones with
+// string keys, which json can encode
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = stringKeys(e)
+		}
+		return list
+	}
+	return v
+}
+
+// configScalar formats a scalar value of the config as a string
+func configScalar(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool:
+		return fmt.Sprint(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("expected a scalar value, got a %T", v)
+}
+
+// loadedConfig is a configuration file which has been read
+type loadedConfig struct {
+	cfg  *config
+	data []byte
+}
+
+// applyProfile applies the values under "profiles.<name>" of the configurations, the
+// profile is selected by the args or else by the "profile" key of the configurations
+func (c *Cortana) applyProfile(loaded []loadedConfig) error {
+	if c.predefined.profile.long == "" && c.predefined.profile.short == "" {
+		return nil
+	}
+	name := c.profile
+	var tables []map[string]interface{}
+	for _, l := range loaded {
+		m := make(map[string]interface{})
+		if err := unmarshalContext(c.parsing.ctx, l.cfg.unmarshaler, l.data, &m); err != nil {
+			return err
+		}
+		tables = append(tables, m)
+		if c.profile != "" {
+			continue
+		}
+		// the later configurations override the default profile of the former ones
+		if v, ok, _ := lookupConfigKey(m, "profile"); ok {
+			if s, ok := v.(string); ok {
+				name = s
+			}
+		}
+	}
+	c.activeProfile = name
+	if name == "" {
+		return nil
+	}
+
+	var found bool
+	var available []string
+	for i, m := range tables {
+		profiles, ok, err := lookupConfigKey(m, "profiles")
+		if err != nil || !ok {
+			continue
+		}
+		for _, k := range tableKeys(profiles) {
+			if !containsString(available, k) {
+				available = append(available, k)
+			}
+		}
+		key := "profiles." + name
+		if _, ok, _ := lookupConfigKey(m, key); !ok {
+			continue
+		}
+		found = true
+
+		path := loaded[i].cfg.path
+		before := c.snapshot()
+		for _, f := range c.parsingFlags() {
+			flagKey := f.configKey
+			if flagKey == "" && f.long != "-" {
+				flagKey = strings.TrimLeft(f.long, "-")
+			}
+			if flagKey == "" {
+				continue
+			}
+			v, ok, err := lookupConfigKey(m, key+"."+flagKey)
+			if err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+			if !ok {
+				continue
+			}
+			if err := applyConfigValue(f, v); err != nil {
+				return fmt.Errorf("%s: config key %s.%s: %v", path, key, flagKey, err)
+			}
+		}
+		c.recordChanges(before, Source{Kind: SourceConfig, Detail: path + " (profile " + name + ")"})
+	}
+	if !found {
+		sort.Strings(available)
+		if len(available) == 0 {
+			return fmt.Errorf("unknown profile %q, no profiles are defined", name)
+		}
+		return fmt.Errorf("unknown profile %q, available profiles: %s", name, strings.Join(available, ", "))
+	}
+	return nil
+}
+
+// tableKeys returns the keys of a table decoded from the configuration
+func tableKeys(v interface{}) []string {
+	var keys []string
+	switch table := v.(type) {
+	case map[string]interface{}:
+		for k := range table {
+			keys = append(keys, k)
+		}
+	case map[interface{}]interface{}:
+		for k := range table {
+			keys = append(keys, fmt.Sprint(k))
+		}
+	}
+	return keys
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
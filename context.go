@@ -4,7 +4,14 @@ package cortana
 type desc struct {
 	title       string
 	description string
-	flags       string
+	synopsis    string   // overrides the first line of the generated flags usage
+	examples    []string // the example invocations listed after the flags
+	footer      string   // the text at the end of the usage
+
+	flags      []*flag
+	nonflags   []*nonflag
+	predefined []*flag // the flags like --help which are not defined by the struct
+	parsed     bool    // the flags have been collected
 }
 
 type context struct {
@@ -12,4 +19,6 @@ type context struct {
 	args    []string
 	desc    desc
 	longest string // the longest path has been searched
+	unknown string // the unknown sub command of a strict command
+	rcArgs  int    // the number of the leading args which come from the rc file
 }
@@ -2,12 +2,17 @@ package cortana
 
 import (
 	"bytes"
+	stdctx "context"
+	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,22 +30,56 @@ type predefined struct {
 		longshort
 		unmarshaler Unmarshaler
 	}
+	profile longshort
+	output  longshort
+	dump    longshort
+	explain longshort
 }
 
 // Cortana is the commander
 type Cortana struct {
-	ctx        context
-	commands   commands
-	predefined predefined
-	configs    []*config
-	envs       []EnvUnmarshaler
-	stdout     io.Writer
-	stderr     io.Writer
-	exitOnErr  bool
+	ctx         context
+	commands    commands
+	predefined  predefined
+	configs     []*config
+	envs        []EnvUnmarshaler
+	stdout      io.Writer
+	stderr      io.Writer
+	exitOnErr   bool
+	exit        func(code int)      // exits the process, os.Exit by default
+	onError     func(err error)     // reports the errors instead of printing them
+	exitCode    func(err error) int // maps the errors to the exit codes
+	rcfile      string
+	abbrevFlags bool
+
+	showDeprecated  bool // show the deprecated names of the flags in the usage
+	caseInsensitive bool // match the long flags case-insensitively
+
+	defaultProviders map[string]func(stdctx.Context) (string, error)
+	tags             tagOptions
+	notFound         func(args []string) error
+	preflights       []preflight
+	cachePreflights  bool // a successful preflight check is not run again
+	recorder         recorder
+	profile          string        // the profile selected by the args
+	activeProfile    string        // the profile selected by the args or the configurations
+	output           string        // the format of Print selected by the args
+	persistent       []interface{} // the structs of the flags parsed for every command
+	beforeRun        []func(cmd *Command, args []string) error
+	afterRun         []func(cmd *Command, err error)
+	middlewares      []Middleware
+	defaultCommand   string            // the command run when the args match no command
+	aliases          map[string]string // the alias paths to the paths of the commands
+	greedyDefault    bool              // the default command takes the unknown positional args too
 
 	parsing struct {
 		flags    []*flag
 		nonflags []*nonflag
+		help     longshort // the help flag after the overrides
+		ctx      stdctx.Context
+		warned   map[string]bool // the deprecated names which have been warned
+		err      error           // the first error reported while parsing
+		dump     bool            // the dump config flag is given
 	}
 
 	// seq keeps the order of adding a command
@@ -68,7 +107,7 @@ func WithStdout(stdout io.Writer) Option {
 
 func WithStderr(stderr io.Writer) Option {
 	return func(c *Cortana) {
-		c.stdout = stderr
+		c.stderr = stderr
 	}
 }
 
@@ -78,6 +117,127 @@ func ExitOnError(b bool) Option {
 	}
 }
 
+// ExitCoder is implemented by the errors which decide the exit code of the process
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// WithExitCodes maps the errors to the exit codes of the process, the code of an
+// ExitCoder in the error chain is used if f is nil or returns 0. The code is -1 if
+// neither of them decides it
+func WithExitCodes(f func(err error) int) Option {
+	return func(c *Cortana) {
+		c.exitCode = f
+	}
+}
+
+// OnError reports the errors by f instead of printing them to the stderr, the
+// process still exits afterwards if ExitOnError is enabled
+func OnError(f func(err error)) Option {
+	return func(c *Cortana) {
+		c.onError = f
+	}
+}
+
+// WithExit replaces os.Exit, which is called with the exit code on errors and with
+// 0 after printing the usage
+func WithExit(exit func(code int)) Option {
+	return func(c *Cortana) {
+		c.exit = exit
+	}
+}
+
+// exitCodeOf classifies the error to the exit code
+func (c *Cortana) exitCodeOf(err error) int {
+	if c.exitCode != nil {
+		if code := c.exitCode(err); code != 0 {
+			return code
+		}
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) && coder.ExitCode() != 0 {
+		return coder.ExitCode()
+	}
+	return -1
+}
+
+// WithRCFile prepends the default args of a user from the rc file to every
+// invocation, so the args from the command line always win. The rc args take part
+// in resolving the command and their values are sourced as SourceRCFile. It is fine
+// if the file does not exist
+func WithRCFile(path string) Option {
+	return func(c *Cortana) {
+		c.rcfile = expandHome(path)
+	}
+}
+
+// DottedFlags names the flags of the nested structs with the field path joined
+// by dots, like --server.port, to mirror the structure of the config. The name of a
+// nested struct can be set by its cortana tag and a dotted long name in the tag of a
+// field is kept as is
+func DottedFlags() Option {
+	return func(c *Cortana) {
+		c.tags.dotted = true
+	}
+}
+
+// WithTagName looks up the cortana tag by the name first, then "cortana" and "lsdd"
+// for compatibility. The tag modifiers like env are also looked up by the name
+// prefixed, like "cli-env" for WithTagName("cli"), before the plain ones
+func WithTagName(name string) Option {
+	return func(c *Cortana) {
+		c.tags.name = name
+	}
+}
+
+// StrictTags reports an error instead of a warning when parsing a struct that has
+// unexported fields with cortana tags
+func StrictTags() Option {
+	return func(c *Cortana) {
+		c.tags.strict = true
+	}
+}
+
+// ExtendedDurations accepts the units "d" (24h) and "w" (7d) for all the duration
+// flags, it could also be enabled for a single flag with the tag duration:"extended"
+func ExtendedDurations() Option {
+	return func(c *Cortana) {
+		c.tags.extendedDuration = true
+	}
+}
+
+// AbbreviatedFlags accepts an unambiguous prefix of a long flag like getopt_long,
+// so --verb means --verbose if no other long flag starts with it
+func AbbreviatedFlags() Option {
+	return func(c *Cortana) {
+		c.abbrevFlags = true
+	}
+}
+
+// CaseInsensitiveFlags matches the long flags case-insensitively, so --Config means
+// --config. The short flags and the values keep their case
+func CaseInsensitiveFlags() Option {
+	return func(c *Cortana) {
+		c.caseInsensitive = true
+	}
+}
+
+// foldFlag folds the case of a long flag if the flags are case-insensitive
+func (c *Cortana) foldFlag(name string) string {
+	if c.caseInsensitive && strings.HasPrefix(name, "--") {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// ShowDeprecatedFlags shows the deprecated names of the flags in the usage, which
+// are hidden by default
+func ShowDeprecatedFlags() Option {
+	return func(c *Cortana) {
+		c.showDeprecated = true
+	}
+}
+
 // ConfFlag parse the configration file path from flags
 func ConfFlag(long, short string, unmarshaler Unmarshaler) Option {
 	return func(c *Cortana) {
@@ -88,6 +248,17 @@ func ConfFlag(long, short string, unmarshaler Unmarshaler) Option {
 	}
 }
 
+// ProfileFlag selects a profile of the configuration file by the flag, the values
+// under "profiles.<name>" are applied after the configuration and before the envs
+// and args. The default profile can be named by the "profile" key of the configuration
+func ProfileFlag(long, short string) Option {
+	return func(c *Cortana) {
+		c.predefined.profile.long = long
+		c.predefined.profile.short = short
+		c.predefined.profile.desc = "name of the profile in the configuration file"
+	}
+}
+
 // New a Cortana commander
 func New(opts ...Option) *Cortana {
 	c := &Cortana{commands: commands{t: btree.New(8)},
@@ -95,6 +266,7 @@ func New(opts ...Option) *Cortana {
 		stdout:    os.Stdout,
 		stderr:    os.Stderr,
 		exitOnErr: true,
+		exit:      os.Exit,
 	}
 	c.predefined.help = longshort{
 		long:  "--help",
@@ -107,12 +279,156 @@ func New(opts ...Option) *Cortana {
 	return c
 }
 
+// checkDuplicates reports the flags sharing a name. A flag tagged with allowdup for
+// the name, like allowdup:"short", wins and the other one loses the name. In the
+// strict mode, a name of the predefined flags except the help one is reported too
+func (c *Cortana) checkDuplicates(flags []*flag, owners map[*flag]string) error {
+	predefined := []string{
+		c.predefined.cfg.long, c.predefined.cfg.short,
+		c.predefined.profile.long, c.predefined.profile.short,
+		c.predefined.output.long, c.predefined.output.short,
+		c.predefined.dump.long, c.predefined.dump.short,
+		c.predefined.explain.long, c.predefined.explain.short,
+	}
+	seen := make(map[string]*flag)
+	for _, f := range flags {
+		for _, kind := range []string{"long", "short"} {
+			name := c.foldFlag(f.long)
+			if kind == "short" {
+				name = f.short
+			}
+			if name == "" || name == "-" {
+				continue
+			}
+			if c.tags.strict && containsString(predefined, name) {
+				return fmt.Errorf("cortana: flag %s of %s collides with a predefined flag", name, owners[f])
+			}
+			prev, ok := seen[name]
+			if !ok {
+				seen[name] = f
+				continue
+			}
+			switch {
+			case containsString(f.allowDup, kind) && !containsString(prev.allowDup, kind):
+				prev.dropName(kind)
+				seen[name] = f
+			case containsString(prev.allowDup, kind) && !containsString(f.allowDup, kind):
+				f.dropName(kind)
+			case prev.long != f.long && kind == "long":
+				return fmt.Errorf("cortana: flag %s of %s and %s of %s are the same case-insensitively",
+					prev.long, owners[prev], f.long, owners[f])
+			default:
+				return fmt.Errorf("cortana: flag %s is defined by both %s and %s", name, owners[prev], owners[f])
+			}
+		}
+	}
+	// the other spellings a flag answers to, the negations are checked last so the
+	// collision is reported as the one of the negation
+	for _, negations := range []bool{false, true} {
+		for _, f := range flags {
+			names := append(append([]string{}, f.aliases...), f.deprecated...)
+			if negations {
+				names = nil
+				if neg := f.negation(); neg != "" {
+					names = []string{neg}
+				}
+			}
+			for _, name := range names {
+				name = c.foldFlag(name)
+				if c.tags.strict && containsString(predefined, name) {
+					return fmt.Errorf("cortana: flag %s of %s collides with a predefined flag", name, owners[f])
+				}
+				prev, ok := seen[name]
+				switch {
+				case !ok:
+					seen[name] = f
+				case negations:
+					return fmt.Errorf("cortana: flag %s of %s collides with the negation of %s of %s, "+
+						"set negate:\"false\" to disable the negation", name, owners[prev], f.long, owners[f])
+				default:
+					return fmt.Errorf("cortana: flag %s is defined by both %s and %s", name, owners[prev], owners[f])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkInterrupted aborts the parse if the context is done, the phase tells what
+// is interrupted
+func (c *Cortana) checkInterrupted(phase string) {
+	if c.parsing.ctx == nil {
+		return
+	}
+	if err := c.parsing.ctx.Err(); err != nil {
+		c.fatal(fmt.Errorf("cortana: parse interrupted while %s: %w", phase, err))
+		panic("abort")
+	}
+}
+
+// yieldHelp drops the spellings of the help flag which are defined by the flags,
+// so a command can use -h for another purpose and still has --help
+func (c *Cortana) yieldHelp(help longshort, flags []*flag) longshort {
+	for _, f := range flags {
+		if help.short != "" && f.short == help.short {
+			c.tracef("the help flag %s yields to the field %s", help.short, f.path)
+			help.short = ""
+		}
+		if help.long != "" && f.long == help.long {
+			c.tracef("the help flag %s yields to the field %s", help.long, f.path)
+			help.long = ""
+		}
+	}
+	return help
+}
+
+// tracef writes the message to the stderr if the env CORTANA_TRACE is set
+func (c *Cortana) tracef(format string, a ...interface{}) {
+	if os.Getenv("CORTANA_TRACE") == "" {
+		return
+	}
+	fmt.Fprintf(c.stderr, "cortana: trace: "+format+"\n", a...)
+}
+
 // fatal exit the process with an error
 func (c *Cortana) fatal(err error) {
-	fmt.Fprintln(c.stderr, err)
+	if c.parsing.err == nil {
+		c.parsing.err = err
+	}
+	c.finishRecord(err)
+	if c.onError != nil {
+		c.onError(err)
+	} else {
+		fmt.Fprintln(c.stderr, err)
+	}
 	if c.exitOnErr {
-		os.Exit(-1)
+		c.exit(c.exitCodeOf(err))
+	}
+}
+
+// usageHint tells how to print the usage, it is appended to the errors which the
+// usage may help with
+func (c *Cortana) usageHint() string {
+	help := c.parsing.help.long
+	if help == "" || help == "-" {
+		help = c.parsing.help.short
+	}
+	if help == "" || help == "-" {
+		return ""
 	}
+	return fmt.Sprintf("\nrun '%s %s' for the usage", c.ctx.name, help)
+}
+
+// Fatal reports the error the same way as the failures of cortana, it is passed to
+// the OnError handler or printed to the configured stderr, and the process exits
+// with the code mapped by WithExitCodes if ExitOnError is enabled
+func (c *Cortana) Fatal(err error) {
+	c.fatal(err)
+}
+
+// Fatalf formats the error and reports it like Fatal
+func (c *Cortana) Fatalf(format string, a ...interface{}) {
+	c.fatal(fmt.Errorf(format, a...))
 }
 
 // Use the cortana options
@@ -122,10 +438,120 @@ func (c *Cortana) Use(opts ...Option) {
 	}
 }
 
-// AddCommand adds a command
-func (c *Cortana) AddCommand(path string, cmd func(), brief string) {
-	c.commands.t.ReplaceOrInsert(&command{Path: path, Proc: cmd, Brief: brief, order: c.seq})
+// AddCommand adds a command, it replaces the command of the same path and reports
+// if there is one
+func (c *Cortana) AddCommand(path string, cmd func(), brief string, opts ...CommandOption) bool {
+	return c.addCommand(&command{Path: path, Proc: cmd, Brief: brief}, opts)
+}
+
+// AddCommandE adds a command whose handler returns an error, LaunchE returns the
+// error and Launch reports it like the failures of cortana
+func (c *Cortana) AddCommandE(path string, cmd func() error, brief string, opts ...CommandOption) bool {
+	proc := func() {
+		if err := cmd(); err != nil {
+			c.fatal(err)
+		}
+	}
+	return c.addCommand(&command{Path: path, Proc: proc, Brief: brief, run: cmd}, opts)
+}
+
+// AddCommandArgs adds a command whose handler takes the args of the command, the
+// same as Args() returns. The handler gets its own copy, so it is free to modify them
+func (c *Cortana) AddCommandArgs(path string, cmd func(args []string), brief string, opts ...CommandOption) bool {
+	proc := func() {
+		cmd(append([]string{}, c.ctx.args...))
+	}
+	return c.addCommand(&command{Path: path, Proc: proc, Brief: brief}, opts)
+}
+
+func (c *Cortana) addCommand(command *command, opts []CommandOption) bool {
+	command.order = c.seq
+	for _, opt := range opts {
+		opt((*Command)(command))
+	}
+	replaced := c.commands.t.ReplaceOrInsert(command) != nil
+	// the names of a replaced command go away with it
+	c.removeAliases(command.Path)
+	if c.aliases == nil && len(command.Aliases) > 0 {
+		c.aliases = make(map[string]string)
+	}
+	for _, name := range command.Aliases {
+		c.aliases[aliasPath(command.Path, name)] = command.Path
+	}
 	c.seq++
+	return replaced
+}
+
+// removeAliases removes the alias names of the command of the path
+func (c *Cortana) removeAliases(path string) {
+	for alias, target := range c.aliases {
+		if target == path {
+			delete(c.aliases, alias)
+		}
+	}
+}
+
+// resolveAlias returns the path of the command if path is an alias name of it
+func (c *Cortana) resolveAlias(path string) string {
+	if target, ok := c.aliases[path]; ok && c.commands.get(path) == nil {
+		return target
+	}
+	return path
+}
+
+// canonicalPath resolves the alias names in each level of the path
+func (c *Cortana) canonicalPath(path string) string {
+	var canonical string
+	for _, word := range strings.Fields(path) {
+		canonical = c.resolveAlias(strings.TrimSpace(canonical + " " + word))
+	}
+	return canonical
+}
+
+// RemoveCommand removes the command of the path, and all the sub commands under it
+// if recursive. The aliases to a removed command are removed as well. It reports if
+// any command is removed
+func (c *Cortana) RemoveCommand(path string, recursive bool) bool {
+	path = strings.Join(strings.Fields(path), " ")
+	var removed []*command
+	if cmd := c.commands.get(path); cmd != nil {
+		removed = append(removed, cmd)
+	}
+	if recursive {
+		prefix := path
+		if prefix != "" {
+			prefix += " "
+		}
+		for _, cmd := range c.commands.scan(prefix) {
+			if cmd.Path != path {
+				removed = append(removed, cmd)
+			}
+		}
+	}
+	// resolve the aliases before the commands are gone
+	for _, cmd := range c.commands.scan("") {
+		if !cmd.Alias {
+			continue
+		}
+		target, _ := c.searchCommand(aliasArgs(cmd.definition))
+		for _, r := range removed {
+			if target == r {
+				removed = append(removed, cmd)
+				break
+			}
+		}
+	}
+	for _, cmd := range removed {
+		c.commands.t.Delete(cmd)
+		c.removeAliases(cmd.Path)
+	}
+	return len(removed) > 0
+}
+
+// AddHiddenCommand adds a command which is runnable but not listed in the usage,
+// Commands or Complete, like the internal maintenance commands
+func (c *Cortana) AddHiddenCommand(path string, cmd func(), brief string, opts ...CommandOption) bool {
+	return c.AddCommand(path, cmd, brief, append(opts, Hidden())...)
 }
 
 // AddRootCommand adds the command without sub path
@@ -133,41 +559,180 @@ func (c *Cortana) AddRootCommand(cmd func()) {
 	c.AddCommand("", cmd, "")
 }
 
+// PersistentFlags registers the struct of the flags which are parsed for every
+// command along with its own flags, like --verbose. The handlers read the values
+// from v after Parse, and a flag of a command can not take the name of one of them
+func (c *Cortana) PersistentFlags(v interface{}) {
+	c.persistent = append(c.persistent, v)
+}
+
 // AddConfig adds a config file
 func (c *Cortana) AddConfig(path string, unmarshaler Unmarshaler) {
-	// expand the path
-	if path != "" && path[0] == '~' {
-		home, _ := os.UserHomeDir()
-		if home != "" {
-			path = home + path[1:]
-		}
+	path, err := normalizePath(path)
+	if err != nil {
+		c.fatal(err)
+		return
 	}
 	cfg := &config{path: path, unmarshaler: unmarshaler}
 	c.configs = append(c.configs, cfg)
 }
 
+// DefaultProvider computes the default value of the flag named by its long or short
+// name, like runtime.NumCPU() for --workers. It is invoked only if no other source
+// supplies the value, and also when rendering the usage unless a static default is
+// given in the tag, which is shown as a placeholder instead
+func (c *Cortana) DefaultProvider(name string, provider func() (string, error)) {
+	c.DefaultProviderContext(name, func(stdctx.Context) (string, error) {
+		return provider()
+	})
+}
+
+// DefaultProviderContext adds the default provider like DefaultProvider, which
+// receives the context of ParseContext, or context.Background() when rendering the
+// usage. It should return once the ctx is done
+func (c *Cortana) DefaultProviderContext(name string, provider func(ctx stdctx.Context) (string, error)) {
+	if c.defaultProviders == nil {
+		c.defaultProviders = make(map[string]func(stdctx.Context) (string, error))
+	}
+	c.defaultProviders[name] = provider
+}
+
+// SetNotFoundHandler handles the args which match no command, including the root
+// command. The handler gets the original args and its error is reported as usual,
+// without a handler the usage is printed
+func (c *Cortana) SetNotFoundHandler(handler func(args []string) error) {
+	c.notFound = handler
+}
+
 func (c *Cortana) AddEnvUnmarshaler(unmarshaler EnvUnmarshaler) {
 	c.envs = append(c.envs, unmarshaler)
 }
 
 // Launch and run commands, os.Args is used if no args supplied
 func (c *Cortana) Launch(args ...string) {
+	c.report(c.LaunchE(args...))
+}
+
+// report reports the error of launching like Launch
+func (c *Cortana) report(err error) {
+	if err == nil {
+		return
+	}
+	// the unknown sub command is reported with the available ones
+	var unknown *UnknownCommandError
+	if errors.As(err, &unknown) && c.ctx.unknown != "" {
+		c.unknownSubcommand()
+		return
+	}
+	c.fatal(err)
+}
+
+// LaunchE runs the command like Launch but returns the error instead of reporting
+// it, the error of a handler added by AddCommandE is returned as is and an unknown
+// command is an *UnknownCommandError. The failures of parsing are still reported by
+// Parse as configured by ExitOnError
+func (c *Cortana) LaunchE(args ...string) error {
+	return c.LaunchContext(stdctx.Background(), args...)
+}
+
+// LaunchContext runs the command like LaunchE, the ctx is passed to the preflight
+// checks
+func (c *Cortana) LaunchContext(ctx stdctx.Context, args ...string) error {
 	if len(args) == 0 {
 		args = os.Args[1:]
 	}
+	return c.launch(ctx, args)
+}
+
+// launch runs the command of the args as they are, os.Args is never used
+func (c *Cortana) launch(ctx stdctx.Context, args []string) error {
+	if stripped, explain := c.stripExplain(args); explain {
+		fmt.Fprint(c.stdout, c.Resolve(stripped).explain())
+		return nil
+	}
 	cmd := c.SearchCommand(args)
+	if c.fallsToDefault(cmd, args) {
+		cmd = c.SearchCommand(append(strings.Fields(c.defaultCommand), args...))
+	}
+	if cmd != nil && c.rcfile != "" {
+		resolved, ctx, err := c.searchRCFile((*command)(cmd), c.ctx)
+		if err != nil {
+			return err
+		}
+		cmd, c.ctx = (*Command)(resolved), ctx
+	}
 	if cmd == nil {
-		c.Usage()
+		if c.ctx.unknown != "" {
+			return c.unknownCommand(c.ctx.name, c.ctx.unknown)
+		}
+		if c.notFound != nil {
+			return c.notFound(args)
+		}
+		// the usage is a hint only if there is nothing similar to suggest
 		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
-			c.fatal(errors.New("unknown command: " + args[0]))
+			err := c.unknownCommand("", args[0])
+			if len(err.Suggestions) == 0 {
+				c.Usage()
+			}
+			return err
 		}
-		return
+		c.Usage()
+		return nil
+	}
+	if err := c.runPreflights(ctx, cmd); err != nil {
+		return err
 	}
-	cmd.Proc()
+	c.startRecord(cmd.Path, args)
+	err := c.execute(cmd)
+	c.finishRecord(err)
+	return err
+}
+
+// SetDefaultCommand runs the command of the path if the args match no command but
+// the root, as if the path were typed, like "serve" for "mytool --port 8080". Only
+// the args led by a flag or no args fall through, so an unknown command is still
+// reported, unless the GreedyDefaultCommand option is used
+func (c *Cortana) SetDefaultCommand(path string) {
+	c.defaultCommand = strings.Join(strings.Fields(path), " ")
+}
+
+// GreedyDefaultCommand lets the default command take the args led by a positional
+// arg as well, so "mytool file.txt" runs it with file.txt instead of failing
+func GreedyDefaultCommand() Option {
+	return func(c *Cortana) {
+		c.greedyDefault = true
+	}
+}
+
+// fallsToDefault reports if the args are handed to the default command, the help
+// flag still prints the usage of the root
+func (c *Cortana) fallsToDefault(cmd *Command, args []string) bool {
+	if c.defaultCommand == "" || (cmd != nil && cmd.Path != "") {
+		return false
+	}
+	if len(args) == 0 {
+		return true
+	}
+	if args[0] == c.predefined.help.long || args[0] == c.predefined.help.short {
+		return false
+	}
+	return strings.HasPrefix(args[0], "-") || c.greedyDefault
 }
 
 // SearchCommand returns the command according the args
 func (c *Cortana) SearchCommand(args []string) *Command {
+	cmd, ctx := c.searchCommand(args)
+	c.ctx = ctx
+	return (*Command)(cmd)
+}
+
+// searchCommand resolves the command and its context without side effects.
+//
+// The words which are a prefix of some command but not a command themselves are
+// held back, if the prefix never reaches a command they are given back to the
+// last matched command as positional args. The deepest command wins, "--" or a
+// command with StrictArgs ends the search and the held back words are kept as args
+func (c *Cortana) searchCommand(args []string) (*command, context) {
 	var cmdArgs []string
 	var maybeArgs []string
 	var path string
@@ -179,13 +744,19 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 		StateCommandArg
 	)
 
-	// reset the search context
-	c.ctx = context{}
-
 	st := StateCommand
 	cmd := c.commands.get(path)
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
+		// the deepest command wins by default, the search stops at "--" or once
+		// a command whose positionals are never sub commands is matched. The
+		// pending words of a prefix, which is not a command, are positional args
+		if arg == "--" || (cmd != nil && cmd.strictArgs && path == cmd.Path) {
+			cmdArgs = append(cmdArgs, maybeArgs...)
+			cmdArgs = append(cmdArgs, args[i:]...)
+			maybeArgs = maybeArgs[:0]
+			break
+		}
 		switch st {
 		case StateCommand:
 			if strings.HasPrefix(arg, "-") {
@@ -193,7 +764,7 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 				cmdArgs = append(cmdArgs, arg)
 				continue
 			}
-			p := strings.TrimSpace(path + " " + arg)
+			p := c.resolveAlias(strings.TrimSpace(path + " " + arg))
 			commands := c.commands.scan(p)
 			if len(commands) > 0 {
 				path = p
@@ -208,11 +779,15 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 				continue
 			}
 			if cmd != nil {
+				// the first positional of a strict command must be one of its sub commands
+				if cmd.strict && path == cmd.Path && len(c.commands.children(path)) > 0 {
+					return nil, context{name: path, longest: path, unknown: arg}
+				}
 				cmdArgs = append(cmdArgs, arg)
 				st = StateCommandArg
 				continue
 			}
-			return nil
+			return nil, context{}
 
 		case StateCommandPrefix:
 			if strings.HasPrefix(arg, "-") {
@@ -221,7 +796,7 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 				continue
 			}
 
-			p := strings.TrimSpace(path + " " + arg)
+			p := c.resolveAlias(strings.TrimSpace(path + " " + arg))
 			commands := c.commands.scan(p)
 			if len(commands) > 0 {
 				path = p
@@ -231,8 +806,14 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 					st = StateCommand
 					continue
 				}
+				maybeArgs = append(maybeArgs, arg)
 				continue
 			}
+			// the prefix leads to nowhere, so the words are args of the command
+			cmdArgs = append(cmdArgs, maybeArgs...)
+			cmdArgs = append(cmdArgs, arg)
+			maybeArgs = maybeArgs[:0]
+			st = StateCommandArg
 
 		case StateOptionFlag:
 			if strings.HasPrefix(arg, "-") {
@@ -240,7 +821,7 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 				continue
 			}
 
-			p := strings.TrimSpace(path + " " + args[i])
+			p := c.resolveAlias(strings.TrimSpace(path + " " + args[i]))
 			commands := c.commands.scan(p)
 			if len(commands) > 0 {
 				path = p
@@ -264,7 +845,7 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 				continue
 			}
 
-			p := strings.TrimSpace(path + " " + args[i])
+			p := c.resolveAlias(strings.TrimSpace(path + " " + args[i]))
 			commands := c.commands.scan(p)
 			if len(commands) > 0 {
 				path = p
@@ -296,12 +877,66 @@ func (c *Cortana) SearchCommand(args []string) *Command {
 	if cmd != nil {
 		name = cmd.Path
 	}
-	c.ctx = context{
+	return cmd, context{
 		name:    name,
 		args:    cmdArgs,
 		longest: path,
 	}
-	return (*Command)(cmd)
+}
+
+// unknownCommand returns the error of the unknown command with the suggestions from
+// the children of the parent, the hidden commands are never suggested
+func (c *Cortana) unknownCommand(parent, name string) *UnknownCommandError {
+	var names []string
+	for _, child := range c.commands.children(parent) {
+		if child.cmd == nil || !child.cmd.hidden {
+			names = append(names, child.name)
+		}
+	}
+	for _, cmd := range c.commands.scan("") {
+		if cmd.hidden || parentPath(cmd.Path) != parent {
+			continue
+		}
+		for _, name := range cmd.Aliases {
+			if c.aliases[aliasPath(cmd.Path, name)] == cmd.Path {
+				names = append(names, name)
+			}
+		}
+	}
+	return &UnknownCommandError{Name: name, Parent: parent, Suggestions: Suggest(name, names)}
+}
+
+// unknownSubcommand reports the unknown sub command of a strict command
+// with its immediate children and the suggestions
+func (c *Cortana) unknownSubcommand() {
+	children := c.commands.children(c.ctx.name)
+	err := c.unknownCommand(c.ctx.name, c.ctx.unknown)
+
+	// the suggestions are listed below rather than in the message
+	out := bytes.NewBuffer(nil)
+	out.WriteString((&UnknownCommandError{Name: err.Name, Parent: err.Parent}).Error() + "\n\nAvailable subcommands:\n\n")
+	for _, child := range children {
+		if child.cmd != nil && child.cmd.hidden {
+			continue
+		}
+		brief := ""
+		if child.cmd != nil {
+			brief = child.cmd.Brief
+		}
+		out.WriteString(fmt.Sprintf("  %-28s%s\n", child.name, brief))
+	}
+	if len(err.Suggestions) > 0 {
+		out.WriteString("\nDid you mean this?\n\n")
+		for _, s := range err.Suggestions {
+			out.WriteString("  " + s + "\n")
+		}
+	}
+	c.fatal(errors.New(strings.TrimRight(out.String(), "\n")))
+}
+
+// Profile returns the name of the active profile, empty if there is none
+func (c *Cortana) Profile() string {
+	return c.activeProfile
 }
 
 // Args returns the args in current context
@@ -309,22 +944,38 @@ func (c *Cortana) Args() []string {
 	return c.ctx.args
 }
 
-// Commands returns all the available commands
+// Commands returns all the available commands, the hidden ones are excluded
 func (c *Cortana) Commands() []*Command {
 	var commands []*Command
 
 	// scan all the commands
-	cmds := c.commands.scan("")
+	cmds := visibleCommands(c.commands.scan(""))
 	for _, c := range cmds {
 		commands = append(commands, (*Command)(c))
 	}
 	return commands
 }
 
+// AllCommands returns all the commands including the hidden ones
+func (c *Cortana) AllCommands() []*Command {
+	var commands []*Command
+	for _, c := range c.commands.scan("") {
+		commands = append(commands, (*Command)(c))
+	}
+	return commands
+}
+
 type parseOption struct {
-	ignoreUnknownArgs bool
-	args              []string
-	onUsage           func(usage string) // a callback after parsing "--help, -h"
+	ignoreUnknownArgs     bool
+	preview               bool       // apply the values leniently without checking the requires
+	help                  *longshort // overrides the help flag
+	ctx                   stdctx.Context
+	unknownBoolFlags      []string // the unknown flags which never take a value
+	stopAtFirstPositional bool
+	args                  []string
+	onUsage               func(usage string) // a callback after parsing "--help, -h"
+	exclusive             [][]string         // the groups of the mutually exclusive flags
+	repeat                RepeatPolicy       // how a flag given more than once is handled
 }
 type ParseOption func(opt *parseOption)
 
@@ -334,6 +985,39 @@ func IgnoreUnknownArgs() ParseOption {
 	}
 }
 
+// HelpFlagOverride replaces the help flag for this parse, an empty name disables
+// the spelling
+func HelpFlagOverride(long, short string) ParseOption {
+	return func(opt *parseOption) {
+		opt.help = &longshort{long: long, short: short, desc: "help for the command"}
+	}
+}
+
+// WithContext aborts the parse once the ctx is done, see ParseContext
+func WithContext(ctx stdctx.Context) ParseOption {
+	return func(opt *parseOption) {
+		opt.ctx = ctx
+	}
+}
+
+// UnknownBoolFlags declares the unknown flags which take no value when ignoring
+// the unknown args. Otherwise an unknown long flag owns the following arg unless
+// it looks like a flag, both are kept in Args() side by side
+func UnknownBoolFlags(names ...string) ParseOption {
+	return func(opt *parseOption) {
+		opt.unknownBoolFlags = append(opt.unknownBoolFlags, names...)
+	}
+}
+
+// StopAtFirstPositional stops parsing flags at the first positional arg like
+// getopt does with POSIXLY_CORRECT, all the args after it are left untouched for the
+// remaining positional fields or Args(), even if they look like flags
+func StopAtFirstPositional() ParseOption {
+	return func(opt *parseOption) {
+		opt.stopAtFirstPositional = true
+	}
+}
+
 func WithArgs(args []string) ParseOption {
 	return func(opt *parseOption) {
 		opt.args = args
@@ -346,32 +1030,179 @@ func OnUsage(f func(usage string)) ParseOption {
 	}
 }
 
-// Parse the flags
+// RepeatPolicy decides the value of a flag given more than once in the args, the
+// lists, maps, counting flags and Values always accumulate the occurrences
+type RepeatPolicy int
+
+const (
+	// RepeatLastWins takes the last value
+	RepeatLastWins RepeatPolicy = iota
+	// RepeatFirstWins takes the first value and ignores the others
+	RepeatFirstWins
+	// RepeatError reports an error
+	RepeatError
+)
+
+// repeatPolicies are the policies named by the repeat tag, like repeat:"error"
+var repeatPolicies = map[string]RepeatPolicy{
+	"last":  RepeatLastWins,
+	"first": RepeatFirstWins,
+	"error": RepeatError,
+}
+
+// OnRepeat sets the policy of the flags given more than once, the tag repeat:"first",
+// repeat:"last" or repeat:"error" overrides it for a single flag
+func OnRepeat(policy RepeatPolicy) ParseOption {
+	return func(opt *parseOption) {
+		opt.repeat = policy
+	}
+}
+
+// MutuallyExclusive rejects the args which give more than one of the flags, like
+// MutuallyExclusive("--json", "--yaml"). Only the flags given in the args count, so
+// the defaults, configs and envs never conflict
+func MutuallyExclusive(names ...string) ParseOption {
+	return func(opt *parseOption) {
+		opt.exclusive = append(opt.exclusive, names)
+	}
+}
+
+// Parse the flags, the values are applied in order:
+//
+//  1. SetDefaults of the structs implementing Defaulter, the nested ones first
+//  2. the default values in the tags
+//  3. the config files, then the selected profile
+//  4. the env unmarshalers
+//  5. the args
+//
+// a later source overrides the earlier ones. Validate of the structs implementing
+// Validator runs after all of them, and Complete of a Completer is only called by
+// CompleteFlag
 func (c *Cortana) Parse(v interface{}, opts ...ParseOption) {
 	if v == nil {
 		return
 	}
+	c.parse([]interface{}{v}, opts...)
+}
+
+// parseE parses the flags like Parse and returns the first error reported, so the
+// caller can stop when ExitOnError is disabled
+func (c *Cortana) parseE(v interface{}, opts ...ParseOption) error {
+	if v == nil {
+		return nil
+	}
+	c.parse([]interface{}{v}, opts...)
+	return c.parsing.err
+}
+
+// ParseContext parses the flags like Parse, the parse is aborted between the sources,
+// like the config files and the default providers, once the ctx is done. The ctx is
+// passed to a ContextUnmarshaler, a ContextEnvUnmarshaler and the providers added by
+// DefaultProviderContext, so they can abort a blocking call
+func (c *Cortana) ParseContext(ctx stdctx.Context, v interface{}, opts ...ParseOption) {
+	if v == nil {
+		return
+	}
+	c.parse([]interface{}{v}, append(opts, WithContext(ctx))...)
+}
+
+// parse the args into all the structs at once
+func (c *Cortana) parse(vs []interface{}, opts ...ParseOption) {
 	// print the usage and exit by default when parsing the usage/help flags
 	opt := parseOption{onUsage: func(usage string) {
 		fmt.Fprint(c.stdout, usage)
-		os.Exit(0)
+		c.exit(0)
 	}}
 	for _, o := range opts {
 		o(&opt)
 	}
 	if opt.args != nil {
-		c.ctx.args = opt.args
+		c.ctx.args, c.ctx.rcArgs = opt.args, 0
+	}
+	c.profile = ""
+	c.activeProfile = ""
+	c.output = ""
+	c.parsing.warned = make(map[string]bool)
+	c.parsing.err = nil
+	c.parsing.dump = false
+	c.parsing.ctx = opt.ctx
+	if c.parsing.ctx == nil {
+		c.parsing.ctx = stdctx.Background()
+	}
+
+	// the persistent flags are parsed along with the ones of every command
+	vs = append(vs[:len(vs):len(vs)], c.persistent...)
+	var types []string // the types of the structs, to report the duplicated flags
+	for _, v := range vs {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+			c.fatal(fmt.Errorf("cortana: Parse requires a pointer to a struct, got %T", v))
+			return
+		}
+		for _, path := range unexportedTags(rv.Elem().Type(), c.tags, "") {
+			if c.tags.strict {
+				c.fatal(errors.New("cortana: field " + path + " is unexported and can not be set"))
+				return
+			}
+			fmt.Fprintln(c.stderr, "cortana: warning: field "+path+" is unexported, its tag is ignored")
+		}
+		types = append(types, rv.Elem().Type().String())
 	}
 
 	// process the defined args
 	c.parsing.flags = nil // reset parsing state, so the Parse function could be reused
 	c.parsing.nonflags = nil
-	flags, nonflags := parseCortanaTags(reflect.ValueOf(v))
-	c.parsing.flags = append(c.parsing.flags, flags...)
-	c.parsing.nonflags = append(c.parsing.nonflags, nonflags...)
+	owners := make(map[*flag]string) // the field paths to report the duplicated flags
+	for i, v := range vs {
+		flags, nonflags := parseCortanaTags(reflect.ValueOf(v), c.tags)
+		for _, f := range flags {
+			owners[f] = f.path
+			if len(vs) > 1 {
+				owners[f] = types[i] + "." + f.path
+			}
+			if i >= len(vs)-len(c.persistent) {
+				owners[f] += " (persistent)"
+				f.persistent()
+			}
+		}
+		c.parsing.flags = append(c.parsing.flags, flags...)
+		c.parsing.nonflags = append(c.parsing.nonflags, nonflags...)
+	}
+	if err := c.checkDuplicates(c.parsing.flags, owners); err != nil {
+		c.fatal(err)
+		return
+	}
+	for name, provider := range c.defaultProviders {
+		if f := c.findFlag(name); f != nil {
+			f.defaultFunc = provider
+		}
+	}
+	c.parsing.help = c.predefined.help
+	if opt.help != nil {
+		c.parsing.help = *opt.help
+	}
+	c.parsing.help = c.yieldHelp(c.parsing.help, c.parsing.flags)
+	if err := c.checkTransforms(); err != nil {
+		c.fatal(err)
+		return
+	}
+	if err := c.checkConstraints(); err != nil {
+		c.fatal(err)
+		return
+	}
+	if err := c.applyExclusive(opt.exclusive); err != nil {
+		c.fatal(err)
+		return
+	}
+	before := c.snapshot()
+	for _, v := range vs {
+		setDefaults(v)
+	}
+	c.recordChanges(before, Source{Kind: SourceDefault})
 	c.collectFlags()
 	c.applyDefaultValues()
 
+	state := c.savePass()
 	for func() (restart bool) {
 		defer func() {
 			if v := recover(); v != nil {
@@ -384,10 +1215,33 @@ func (c *Cortana) Parse(v interface{}, opts ...ParseOption) {
 				}
 			}
 		}()
-		c.unmarshalConfigs(v)
-		c.unmarshalEnvs(v)
-		c.unmarshalArgs(opt.ignoreUnknownArgs, opt.onUsage)
-		c.checkRequires()
+		c.resetPass(state)
+		c.unmarshalConfigs(vs)
+		c.unmarshalEnvs(vs)
+		c.checkInterrupted("parsing the args")
+		c.unmarshalArgs(&opt)
+		c.applyDefaultProviders()
+		if err := c.applyTransforms(); err != nil {
+			c.fatal(err)
+		}
+		// the values are dumped before they are validated, so a broken configuration
+		// can be inspected too
+		if c.parsing.dump && !opt.preview {
+			opt.onUsage(c.dumpConfig())
+			panic("abort")
+		}
+		if !opt.preview {
+			if err := c.validateValues(); err != nil {
+				c.fatal(err)
+			}
+			c.checkRequires()
+			for _, v := range vs {
+				if err := validateStructs(v); err != nil {
+					c.fatal(fmt.Errorf("%w%s", err, c.usageHint()))
+					break
+				}
+			}
+		}
 		return false
 	}() {
 	}
@@ -404,6 +1258,21 @@ func (c *Cortana) Description(text string) {
 	c.ctx.desc.description = text
 }
 
+// Synopsis overrides the generated synopsis line of the usage for the command
+func (c *Cortana) Synopsis(text string) {
+	c.ctx.desc.synopsis = text
+}
+
+// Examples lists the example invocations in the usage of the command
+func (c *Cortana) Examples(examples ...string) {
+	c.ctx.desc.examples = append(c.ctx.desc.examples, examples...)
+}
+
+// Footer ends the usage of the command with the text
+func (c *Cortana) Footer(text string) {
+	c.ctx.desc.footer = text
+}
+
 // Usage prints the usage
 func (c *Cortana) Usage() {
 	fmt.Fprint(c.stdout, c.UsageString())
@@ -411,173 +1280,199 @@ func (c *Cortana) Usage() {
 
 // Usage returns the usage string
 func (c *Cortana) UsageString() string {
-	out := bytes.NewBuffer(nil)
-	if c.ctx.desc.title != "" {
-		out.WriteString(c.ctx.desc.title + "\n\n")
-	}
-	if c.ctx.desc.description != "" {
-		out.WriteString(c.ctx.desc.description + "\n\n")
-	}
+	return renderUsage(c.usageModel(&c.ctx))
+}
 
-	//  print the aliailable commands
-	commands := c.commands.scan(c.ctx.longest)
-	// ignore the command itself
-	if len(commands) > 0 && commands[0].Path == c.ctx.name {
-		commands = commands[1:]
+// UsageOf returns the usage of the command without executing it, the flags are
+// rendered only if the command is added with the WithFlags option
+func (c *Cortana) UsageOf(path string) (string, error) {
+	m, err := c.UsageModelOf(path)
+	if err != nil {
+		return "", err
 	}
-	if len(commands) > 0 {
-		out.WriteString("Available commands:\n\n")
-		sort.Sort(orderedCommands(commands))
+	return renderUsage(m), nil
+}
 
-		cmds := bytes.NewBuffer(nil)
-		alias := bytes.NewBuffer(nil)
-		for _, cmd := range commands {
-			writeString := cmds.WriteString
-			if cmd.Alias {
-				writeString = alias.WriteString
+// contextOf returns the context of the command to render its usage, the flags
+// are collected only if the command is added with the WithFlags option
+func (c *Cortana) contextOf(path string) *context {
+	ctx := &context{name: path, longest: path}
+	if cmd := c.commands.get(path); cmd != nil && cmd.options != nil {
+		// parse the tags against a fresh instance, so no live struct is touched
+		flags, nonflags := parseCortanaTags(reflect.New(cmd.options), c.tags)
+		for _, v := range c.persistent {
+			persistent, _ := parseCortanaTags(reflect.New(reflect.TypeOf(v).Elem()), c.tags)
+			for _, f := range persistent {
+				f.persistent()
 			}
-			writeString(fmt.Sprintf("%-30s%s\n", cmd.Path, cmd.Brief))
+			flags = append(flags, persistent...)
 		}
-		out.WriteString(cmds.String() + "\n\n")
-		if alias.Len() > 0 {
-			out.WriteString("Alias commands:\n\n")
-			out.WriteString(alias.String() + "\n")
-		}
-	}
-
-	if c.ctx.desc.flags != "" {
-		out.WriteString("Usage:" + c.ctx.desc.flags + "\n")
+		ctx.desc.flags, ctx.desc.nonflags = visibleFlags(flags), nonflags
+		ctx.desc.predefined = c.predefinedFlags(c.yieldHelp(c.predefined.help, flags))
+		ctx.desc.parsed = true
 	}
-	return out.String()
+	return ctx
 }
 
-// Complete returns all the commands that has prefix
+// Complete returns all the commands that has prefix, the hidden ones are excluded
 func (c *Cortana) Complete(prefix string) []*Command {
-	cmds := c.commands.scan(prefix)
+	cmds := visibleCommands(c.commands.scan(prefix))
+	// the alias names are completed as the commands of their own paths
+	var aliases []string
+	for alias := range c.aliases {
+		if strings.HasPrefix(alias, prefix) && c.commands.get(alias) == nil {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if cmd := c.commands.get(c.aliases[alias]); cmd != nil && !cmd.hidden {
+			named := *cmd
+			named.Path = alias
+			cmds = append(cmds, &named)
+		}
+	}
 	return *(*[]*Command)(unsafe.Pointer(&cmds))
 }
 
+// Alias adds a command which runs the definition with the args appended, like "rmi"
+// for "rm -i". The args can be placed by $1, $2 and $@ as well, like "files copy
+// --from $1 --to $2". The Aliases option is the way to give a command another name
 func (c *Cortana) Alias(name, definition string) {
 	processAlias := func() {
-		c.alias(definition)
+		if err := c.alias(name, definition); err != nil {
+			c.fatal(err)
+		}
+	}
+	run := func() error {
+		return c.alias(name, definition)
 	}
 	alias := fmt.Sprintf("alias %-5s = %-20s", name, definition)
-	c.commands.t.ReplaceOrInsert(&command{Path: name, Proc: processAlias, Brief: alias, order: c.seq, Alias: true})
+	c.commands.t.ReplaceOrInsert(&command{Path: name, Proc: processAlias, Brief: alias, order: c.seq, Alias: true,
+		definition: definition, run: run})
 	c.seq++
 }
-func (c *Cortana) alias(definition string) {
-	quoted := false
-	args := strings.FieldsFunc(definition, func(r rune) bool {
-		if r == '"' {
-			quoted = !quoted
-		}
-		return unicode.IsSpace(r) && !quoted
-	})
-	cmd := c.SearchCommand(append(args, c.ctx.args...))
+func (c *Cortana) alias(name, definition string) error {
+	args, err := expandAlias(definition, c.ctx.args)
+	if err != nil {
+		return fmt.Errorf("alias %s: %v", name, err)
+	}
+	cmd := c.SearchCommand(args)
 	if cmd == nil {
 		c.Usage()
-		return
+		return nil
 	}
-	cmd.Proc()
+	return c.execute(cmd)
 }
 
-func (c *Cortana) collectFlags() {
-	flags, nonflags := c.parsing.flags, c.parsing.nonflags
-
-	w := bytes.NewBuffer(nil)
-	w.WriteString(c.ctx.name)
-	if len(flags) > 0 {
-		w.WriteString(" [options]")
-	}
-	for _, nf := range nonflags {
-		name := nf.long
-		if name == "" {
-			name = nf.name
-		}
-		if nf.rv.Kind() == reflect.Slice {
-			name += "..."
+// aliasPlaceholder matches the placeholders of the args in a definition, like $1 or $@
+var aliasPlaceholder = regexp.MustCompile(`\$(\d+|@)`)
+
+// expandAlias substitutes the placeholders of the definition with the args, $1 is
+// the first arg and $@ is the args after the last numbered one used. The args which
+// are not used are appended, unless $@ takes them
+func expandAlias(definition string, args []string) ([]string, error) {
+	words := aliasArgs(definition)
+	used := 0
+	for _, word := range words {
+		for _, m := range aliasPlaceholder.FindAllStringSubmatch(word, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil && n > used {
+				used = n
+			}
 		}
-		if nf.required {
-			w.WriteString(" <" + name + ">")
-		} else {
-			w.WriteString(" [" + name + "]")
+	}
+	if used > len(args) {
+		return nil, fmt.Errorf("no arg for $%d, %q takes %d args, got %d", used, definition, used, len(args))
+	}
+	rest := args[used:]
+
+	var expanded []string
+	var variadic bool
+	for _, word := range words {
+		// a single $@ expands to the args as they are, spaces included
+		if word == "$@" {
+			expanded = append(expanded, rest...)
+			variadic = true
+			continue
 		}
+		expanded = append(expanded, aliasPlaceholder.ReplaceAllStringFunc(word, func(p string) string {
+			if p == "$@" {
+				variadic = true
+				return strings.Join(rest, " ")
+			}
+			n, err := strconv.Atoi(p[1:])
+			if err != nil || n == 0 {
+				return p
+			}
+			return args[n-1]
+		}))
 	}
-	w.WriteString("\n\n")
-
-	if c.predefined.help.short != "" || c.predefined.help.long != "" {
-		flags = append(flags, &flag{
-			long:        c.predefined.help.long,
-			short:       c.predefined.help.short,
-			description: c.predefined.help.desc,
-			rv:          reflect.ValueOf(false),
-		})
+	if !variadic {
+		expanded = append(expanded, rest...)
 	}
-	if c.predefined.cfg.short != "" || c.predefined.cfg.long != "" {
-		path := ""
-		for i, cfg := range c.configs {
-			if i == len(c.configs)-1 {
-				path += cfg.path
-			} else {
-				path += cfg.path + ","
+	return expanded, nil
+}
+
+// aliasArgs splits the definition of an alias into the args, the quoted spaces are kept
+// and the quotes are stripped, like "hello world" for one arg
+func aliasArgs(definition string) []string {
+	var args []string
+	var arg strings.Builder
+	quoted, inArg := false, false
+	for _, r := range definition {
+		switch {
+		case r == '"':
+			quoted, inArg = !quoted, true
+		case unicode.IsSpace(r) && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
 			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
 		}
-		flags = append(flags, &flag{
-			long:         c.predefined.cfg.long,
-			short:        c.predefined.cfg.short,
-			description:  c.predefined.cfg.desc,
-			required:     true,
-			defaultValue: path,
-		})
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+func (c *Cortana) collectFlags() {
+	// the hidden flags are parsed but never described
+	c.ctx.desc.flags, c.ctx.desc.nonflags = visibleFlags(c.parsing.flags), c.parsing.nonflags
+	c.ctx.desc.predefined = c.predefinedFlags(c.parsing.help)
+	c.ctx.desc.parsed = true
+	if c.predefined.cfg.short != "" || c.predefined.cfg.long != "" {
 		c.configs = append(c.configs, &config{
 			path:        "", // this should be determined by parsing the args
 			unmarshaler: c.predefined.cfg.unmarshaler,
 		})
 	}
-	for _, f := range flags {
-		var flag string
-		if f.short != "-" && f.short != "" {
-			flag += f.short
-		}
-		if f.long != "-" {
-			if f.short != "-" && f.short != "" {
-				flag += ", " + f.long
-			} else {
-				flag += "    " + f.long
-			}
-		}
-		if f.rv.Kind() != reflect.Bool {
-			if f.long != "-" {
-				flag += " <" + strings.TrimLeft(f.long, "-") + ">"
-			} else {
-				flag += " <" + strings.ToLower(f.name) + ">"
-			}
-		}
-		if len(flag) > 30 {
-			// align with 32 spaces
-			flag += "\n                                "
-		}
-		if !f.required && f.rv.Kind() != reflect.Bool {
-			s := wordWrapWithPrefix(fmt.Sprintf("  %-30s ", flag), f.description, 50, 33) // 30+ 3 spaces
-			defaultValue := fmt.Sprintf("(default=%s)\n", f.defaultValue)
-			// if no default value, use its zero value
-			if f.defaultValue == "" {
-				defaultValue = fmt.Sprintf("(default=%v)\n", f.rv.Interface())
-				if f.rv.Kind() == reflect.String {
-					defaultValue = fmt.Sprintf("(default=%q)\n", f.rv.Interface())
-				}
-			}
-			w.WriteString(s + defaultValue)
-		} else {
-			s := wordWrapWithPrefix(fmt.Sprintf("  %-30s ", flag), f.description, 50, 33)
-			w.WriteString(s + "\n")
-		}
+}
+
+// parseCortanaTags parses the tags of the struct, a malformed tag is a mistake of
+// the program, so it panics
+func parseCortanaTags(rv reflect.Value, opts tagOptions) ([]*flag, []*nonflag) {
+	flags, nonflags, err := parseTags(rv, opts)
+	if err != nil {
+		panic(err.Error())
 	}
+	return flags, nonflags
+}
 
-	c.ctx.desc.flags = w.String()
+// parseTags parses the tags of the struct like parseCortanaTags but returns the
+// error of a malformed tag, it is for linting the tags like Inspect
+func parseTags(rv reflect.Value, opts tagOptions) ([]*flag, []*nonflag, error) {
+	return parseStructTags(rv, opts, "", "", "")
 }
 
-func parseCortanaTags(rv reflect.Value) ([]*flag, []*nonflag) {
+// parseStructTags parses the tags of the struct, prefix is the dotted path of
+// the struct if it is nested, fieldPath is the path of its field and namePrefix is
+// prepended to the long flags, like "db-" by the tag cortana:"prefix=db-"
+func parseStructTags(rv reflect.Value, opts tagOptions, prefix, fieldPath, namePrefix string) ([]*flag, []*nonflag, error) {
 	flags := make([]*flag, 0)
 	nonflags := make([]*nonflag, 0)
 	for rv.Kind() == reflect.Ptr {
@@ -588,19 +1483,110 @@ func parseCortanaTags(rv reflect.Value) ([]*flag, []*nonflag) {
 	for i := 0; i < rt.NumField(); i++ {
 		ft := rt.Field(i)
 		fv := rv.Field(i)
-		if fv.Kind() == reflect.Struct {
-			f, nf := parseCortanaTags(fv)
+		// the exported fields of an embedded struct are settable even if it is unexported
+		if ft.PkgPath != "" && !(ft.Anonymous && fv.Kind() == reflect.Struct) {
+			continue
+		}
+		// the field or the whole nested struct is ignored with cortana:"-" like encoding/json
+		if opts.tag(ft.Tag) == "-" {
+			continue
+		}
+		// a struct decoded from json is a single flag rather than nested flags
+		if fv.Kind() == reflect.Struct && !isText(fv.Type()) && opts.get(ft.Tag, "format") != "json" {
+			// the prefixes of the nested structs compose, like db-primary-host
+			name, structPrefix := opts.tag(ft.Tag), namePrefix
+			if strings.HasPrefix(name, "prefix=") {
+				name, structPrefix = "", namePrefix+strings.TrimPrefix(name, "prefix=")
+			}
+			path := prefix
+			if opts.dotted && !ft.Anonymous {
+				if name == "" {
+					name = kebabCase(ft.Name)
+				}
+				path = strings.TrimPrefix(prefix+"."+name, ".")
+			}
+			f, nf, err := parseStructTags(fv, opts, path, fieldPath+ft.Name+".", structPrefix)
+			if err != nil {
+				return nil, nil, err
+			}
 			flags = append(flags, f...)
 			nonflags = append(nonflags, nf...)
 			continue
 		}
 
-		tag := ft.Tag.Get("cortana")
-		if tag == "" {
-			tag = ft.Tag.Get("lsdd") // lsdd is short for (long short default description)
+		tag := opts.tag(ft.Tag)
+		f, err := parseFlag(tag, ft.Name, fv)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cortana: field %s: %v", fieldPath+ft.Name, err)
+		}
+		f.path = fieldPath + ft.Name
+		// the short flags are dropped in a prefixed struct, they would collide anyway
+		if namePrefix != "" && strings.HasPrefix(f.long, "--") {
+			f.long = "--" + namePrefix + f.long[2:]
+			f.short = "-"
+			for i, alias := range f.aliases {
+				f.aliases[i] = "--" + namePrefix + strings.TrimLeft(alias, "-")
+			}
+		}
+		f.configKey = opts.get(ft.Tag, "config")
+		f.extendedDuration = opts.extendedDuration || opts.get(ft.Tag, "duration") == "extended"
+		f.optArg, f.hasOptArg = opts.lookup(ft.Tag, "optarg")
+		if name := opts.get(ft.Tag, "defaultfunc"); name != "" {
+			f.defaultFunc = methodProvider(rv, name)
+		}
+		f.transforms = splitList(opts.get(ft.Tag, "transform"))
+		f.join, f.hasJoin = opts.lookup(ft.Tag, "join")
+		f.requiredIf = splitList(opts.get(ft.Tag, "requiredif"))
+		f.requiredUnless = splitList(opts.get(ft.Tag, "requiredunless"))
+		f.allowDup = splitList(opts.get(ft.Tag, "allowdup"))
+		f.layout = opts.get(ft.Tag, "layout")
+		f.schemes = splitList(opts.get(ft.Tag, "schemes"))
+		_, f.count = opts.lookup(ft.Tag, "count")
+		f.negatable = f.kind() == reflect.Bool && opts.get(ft.Tag, "negate") != "false"
+		f.sep = opts.get(ft.Tag, "sep")
+		f.pattern = opts.get(ft.Tag, "pattern")
+		f.min, f.max = opts.get(ft.Tag, "min"), opts.get(ft.Tag, "max")
+		_, f.hidden = opts.lookup(ft.Tag, "hidden")
+		_, f.secret = opts.lookup(ft.Tag, "secret")
+		f.deprecated = splitList(opts.get(ft.Tag, "deprecated"))
+		f.env = opts.get(ft.Tag, "env")
+		f.mode = opts.get(ft.Tag, "mode")
+		f.repeat = opts.get(ft.Tag, "repeat")
+		f.format = opts.get(ft.Tag, "format")
+		f.encoding = opts.get(ft.Tag, "encoding")
+		f.placeholder = opts.get(ft.Tag, "placeholder")
+		_, f.permOnly = opts.lookup(ft.Tag, "permonly")
+		_, f.rest = opts.lookup(ft.Tag, "rest")
+		if typePlaceholder(fv.Type()) == "<file>" && f.complete == nil {
+			f.complete = completeFiles
+		}
+		// the choices are completed unless the candidates are declared
+		f.choices = splitList(opts.get(ft.Tag, "choices"))
+		if f.candidates = splitList(opts.get(ft.Tag, "complete")); len(f.candidates) == 0 {
+			f.candidates = f.choices
+		}
+		if len(f.candidates) > 0 {
+			f.complete = completeChoices(f.candidates)
+		}
+		if rv.CanAddr() {
+			if completer, ok := rv.Addr().Interface().(Completer); ok {
+				f.complete = completeField(completer, ft.Name, f.complete)
+			}
 		}
-		f := parseFlag(tag, ft.Name, fv)
 		if strings.HasPrefix(f.long, "-") {
+			// a dotted name in the tag overrides the generated one
+			if prefix != "" && strings.HasPrefix(f.long, "--") && !strings.Contains(f.long, ".") {
+				f.long = "--" + prefix + "." + f.long[2:]
+				for i, alias := range f.aliases {
+					f.aliases[i] = "--" + prefix + "." + strings.TrimLeft(alias, "-")
+				}
+			}
+			if prefix != "" {
+				f.group = strings.SplitN(prefix, ".", 2)[0]
+			}
+			if group := opts.get(ft.Tag, "group"); group != "" {
+				f.group = group
+			}
 			if f.long != "-" || f.short != "-" {
 				flags = append(flags, f)
 			}
@@ -609,17 +1595,68 @@ func parseCortanaTags(rv reflect.Value) ([]*flag, []*nonflag) {
 			nonflags = append(nonflags, &nf)
 		}
 	}
-	return flags, nonflags
+	return flags, nonflags, nil
+}
+
+// unexportedTags returns the paths of the unexported fields which have cortana tags
+func unexportedTags(rt reflect.Type, opts tagOptions, path string) []string {
+	var paths []string
+	for i := 0; i < rt.NumField(); i++ {
+		ft := rt.Field(i)
+		if opts.tag(ft.Tag) == "-" {
+			continue
+		}
+		if ft.Type.Kind() == reflect.Struct && !isText(ft.Type) && (ft.PkgPath == "" || ft.Anonymous) &&
+			opts.get(ft.Tag, "format") != "json" {
+			paths = append(paths, unexportedTags(ft.Type, opts, path+ft.Name+".")...)
+			continue
+		}
+		if ft.PkgPath == "" {
+			continue
+		}
+		if opts.tag(ft.Tag) != "" {
+			paths = append(paths, path+ft.Name)
+		}
+	}
+	return paths
 }
-func buildArgsIndex(flags []*flag) map[string]*flag {
+
+// kebabCase converts a field name like LogLevel to log-level
+func kebabCase(name string) string {
+	b := &strings.Builder{}
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// split words, but keep the acronyms like "TLS" together
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func buildArgsIndex(flags []*flag, fold func(name string) string) map[string]*flag {
 	flagsIdx := make(map[string]*flag)
 	for _, f := range flags {
 		if f.long != "" {
-			flagsIdx[f.long] = f
+			flagsIdx[fold(f.long)] = f
 		}
 		if f.short != "" {
 			flagsIdx[f.short] = f
 		}
+		if neg := f.negation(); neg != "" {
+			flagsIdx[fold(neg)] = f
+		}
+		for _, name := range f.deprecated {
+			flagsIdx[fold(name)] = f
+		}
+		for _, name := range f.aliases {
+			flagsIdx[fold(name)] = f
+		}
 	}
 	return flagsIdx
 }
@@ -628,9 +1665,12 @@ func (c *Cortana) applyDefaultValues() {
 		if nf.required {
 			continue
 		}
-		if err := applyValue(nf.rv, nf.defaultValue); err != nil {
+		if err := applyDefault((*flag)(nf), nf.rv); err != nil {
 			c.fatal(err)
 		}
+		if nf.defaultValue != "" {
+			nf.source = Source{Kind: SourceDefault}
+		}
 	}
 	for _, f := range c.parsing.flags {
 		if f.required {
@@ -639,59 +1679,235 @@ func (c *Cortana) applyDefaultValues() {
 		if f.rv.Kind() == reflect.Slice && f.defaultValue == "nil" {
 			continue
 		}
-		if err := applyValue(f.rv, f.defaultValue); err != nil {
+		// the default value is only a placeholder in the usage if there is a provider
+		if f.defaultFunc != nil {
+			continue
+		}
+		if err := applyDefault(f, f.rv); err != nil {
 			c.fatal(err)
 		}
+		if f.defaultValue != "" {
+			f.source = Source{Kind: SourceDefault}
+		}
 	}
 }
-func applyValue(v reflect.Value, s string) error {
+
+// applyDefault applies the default value in the tag to v, the entries of a map are
+// separated by ';' as the tag is separated by ',', like a=1;b=2
+func applyDefault(f *flag, v reflect.Value) error {
+	if v.Kind() != reflect.Map {
+		return applyValue(f, v, f.defaultValue)
+	}
+	for _, entry := range splitEscaped(f.defaultValue, ";") {
+		if err := applyValue(f, v, strings.TrimSpace(entry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func applyValue(f *flag, v reflect.Value, s string) error {
 	if s == "" {
 		return nil
 	}
+	if !v.CanSet() {
+		return errors.New("field " + f.path + " can not be set")
+	}
+	// a json literal replaces the whole value, like a struct or a slice of structs
+	if f.format == "json" {
+		e := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), e.Interface()); err != nil {
+			return fmt.Errorf("invalid json for %s: %v", f.displayName(), err)
+		}
+		v.Set(e.Elem())
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(valueType) {
+		if err := v.Addr().Interface().(Value).Set(s); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", s, f.displayName(), err)
+		}
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := parseTime(s, f.layout)
+		if err != nil {
+			return fmt.Errorf("invalid time %q for %s: %v", s, f.displayName(), err)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if v.Type() == ipNetType {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", s, f.displayName(), err)
+		}
+		v.Set(reflect.ValueOf(*ipnet))
+		return nil
+	}
+	if v.Type() == fileType {
+		file, err := parseFile(s, f.mode)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(file))
+		return nil
+	}
+	if v.Type() == urlType {
+		u, err := parseURL(s, f.schemes)
+		if err != nil {
+			return fmt.Errorf("invalid url %q for %s: %v", s, f.displayName(), err)
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	// the custom types like net.IP parse the text themselves
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", s, f.displayName(), err)
+		}
+		return nil
+	}
+	// a file mode is octal whatever the prefix is, so 0644 is never decimal 644
+	if f.isFileMode(v.Type()) {
+		mode, err := parseFileMode(s, f.permOnly)
+		if err != nil {
+			return fmt.Errorf("invalid file mode %q for %s: %v", s, f.displayName(), err)
+		}
+		v.SetUint(mode)
+		return nil
+	}
+	if isBytes(v.Type()) {
+		b, err := decodeBytes(s, f.encoding)
+		if err != nil {
+			return fmt.Errorf("invalid %s value for %s: %v", f.encoding, f.displayName(), err)
+		}
+		v.Set(reflect.ValueOf(b).Convert(v.Type()))
+		return nil
+	}
 	switch v.Kind() {
 	case reflect.String:
 		v.SetString(s)
-	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		var i int64
 		var d time.Duration
 		var err error
 		if v.Type() == reflect.TypeOf(time.Duration(0)) {
-			d, err = time.ParseDuration(s)
+			d, err = parseDuration(s, f.extendedDuration)
 			i = int64(d)
 		} else {
-			i, err = strconv.ParseInt(s, 10, 64)
+			i, err = strconv.ParseInt(s, intBase(s), v.Type().Bits())
 		}
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid value %q for %s: %v", s, f.displayName(), err)
 		}
 		v.SetInt(i)
-	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		u, err := strconv.ParseUint(s, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, intBase(s), v.Type().Bits())
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid value %q for %s: %v", s, f.displayName(), err)
 		}
 		v.SetUint(u)
 	case reflect.Float32, reflect.Float64:
-		f, err := strconv.ParseFloat(s, 64)
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
 		if err != nil {
-			return err
+			return fmt.Errorf("invalid value %q for %s: %v", s, f.displayName(), err)
 		}
-		v.SetFloat(f)
+		v.SetFloat(n)
 	case reflect.Bool:
-		b, err := strconv.ParseBool(s)
+		b, err := parseBool(s)
 		if err != nil {
+			return fmt.Errorf("%s: %v", f.displayName(), err)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// the value is split by the separator if any, like --tag a,b with sep:","
+		elems := []string{s}
+		if f.sep != "" {
+			elems = splitEscaped(s, f.sep)
+		}
+		for _, elem := range elems {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := applyValue(f, e, elem); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, e))
+		}
+	case reflect.Array:
+		// the elements are filled in order by the repeated values or a comma separated
+		// one, a scratch value like the default being checked is filled from the start
+		filled := new(int)
+		if f.isValueOf(v) {
+			filled = &f.filled
+		}
+		sep := f.sep
+		if sep == "" {
+			sep = ","
+		}
+		for _, elem := range splitEscaped(s, sep) {
+			if *filled >= v.Len() {
+				return fmt.Errorf("%s takes at most %d values, got %q", f.displayName(), v.Len(), s)
+			}
+			if err := applyValue(f, v.Index(*filled), strings.TrimSpace(elem)); err != nil {
+				return err
+			}
+			*filled++
+		}
+	case reflect.Map:
+		// the entries are accumulated and the later one wins for the same key
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s expects key=value, got %q", f.displayName(), s)
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := applyValue(f, key, kv[0]); err != nil {
 			return err
 		}
-		v.SetBool(b)
-	case reflect.Slice:
 		e := reflect.New(v.Type().Elem()).Elem()
-		if err := applyValue(e, s); err != nil {
+		if err := applyValue(f, e, kv[1]); err != nil {
+			// the error of the value names the flag already
+			return fmt.Errorf("key %q: %v", kv[0], err)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(key, e)
+	case reflect.Ptr:
+		// allocate the pointee and keep the pointer untouched if failed
+		e := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			e.Elem().Set(v.Elem())
+		}
+		if err := applyValue(f, e.Elem(), s); err != nil {
 			return err
 		}
-		v.Set(reflect.Append(v, e))
+		v.Set(e)
+	default:
+		return fmt.Errorf("unsupported type %s of field %s", v.Type(), f.path)
 	}
 	return nil
 }
+
+// applyDefaultProviders applies the computed default values to the flags which
+// are not set by any other source
+func (c *Cortana) applyDefaultProviders() {
+	for _, f := range c.parsing.flags {
+		if f.defaultFunc == nil || f.isSet() {
+			continue
+		}
+		c.checkInterrupted("computing the default value of " + f.displayName())
+		value, err := f.defaultFunc(c.parsing.ctx)
+		if err != nil {
+			c.checkInterrupted("computing the default value of " + f.displayName())
+			c.fatal(fmt.Errorf("default value of %s: %v", f.displayName(), err))
+			continue
+		}
+		if err := applyValue(f, f.rv, value); err != nil {
+			c.fatal(err)
+			continue
+		}
+		f.source = Source{Kind: SourceDefault}
+	}
+}
+
 func (c *Cortana) checkRequires() {
 	flags, nonflags := c.parsing.flags, c.parsing.nonflags
 
@@ -699,14 +1915,14 @@ func (c *Cortana) checkRequires() {
 	// check the nonflags
 	i := 0
 	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
+		if strings.HasPrefix(arg, "-") && arg != "-" {
 			break
 		}
 		i++
 	}
 	if i < len(nonflags) {
 		for _, nf := range nonflags[i:] {
-			if nf.required && nf.rv.IsZero() {
+			if nf.required && isUnset(nf.rv) {
 				c.fatal(errors.New("<" + nf.long + "> is required"))
 			}
 		}
@@ -722,13 +1938,21 @@ func (c *Cortana) checkRequires() {
 		if !f.required {
 			continue
 		}
+		// a partially filled array is missing, like --size 800 for a [2]int
+		if f.rv.Kind() == reflect.Array && f.isSet() && f.filled < f.rv.Len() {
+			c.fatal(fmt.Errorf("%s is required with %d values, got %d", f.displayName(), f.rv.Len(), f.filled))
+			continue
+		}
 		if _, ok := argsIdx[f.long]; ok {
 			continue
 		}
 		if _, ok := argsIdx[f.short]; ok {
 			continue
 		}
-		if !f.rv.IsZero() {
+		if f.isSet() {
+			continue
+		}
+		if !isUnset(f.rv) {
 			continue
 		}
 
@@ -739,36 +1963,221 @@ func (c *Cortana) checkRequires() {
 			c.fatal(errors.New(f.short + " is required"))
 		}
 	}
+	c.checkConditionalRequires()
+	c.checkExclusive()
+}
+
+// applyExclusive records the flags excluded by each of the mutually exclusive flags
+func (c *Cortana) applyExclusive(groups [][]string) error {
+	for _, names := range groups {
+		var group []*flag
+		for _, name := range names {
+			f := c.findFlag(name)
+			if f == nil {
+				return fmt.Errorf("cortana: unknown flag %s of the mutually exclusive group %s", name, strings.Join(names, ", "))
+			}
+			group = append(group, f)
+		}
+		for _, f := range group {
+			for _, other := range group {
+				if other != f && !containsString(f.excludes, other.displayName()) {
+					f.excludes = append(f.excludes, other.displayName())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkExclusive checks if more than one of the mutually exclusive flags are given
+func (c *Cortana) checkExclusive() {
+	for _, f := range c.parsing.flags {
+		if !f.source.isArgs() {
+			continue
+		}
+		for _, name := range f.excludes {
+			if other := c.findFlag(name); other != nil && other.source.isArgs() {
+				c.fatal(errors.New(f.displayName() + " and " + other.displayName() + " are mutually exclusive"))
+				return
+			}
+		}
+	}
+}
+
+// checkConditionalRequires checks the flags with the requiredif and requiredunless tags
+func (c *Cortana) checkConditionalRequires() {
+	for _, f := range c.parsing.flags {
+		if f.isSet() {
+			continue
+		}
+		for _, cond := range f.requiredIf {
+			name, value, hasValue := splitCondition(cond)
+			other := c.findFlag(name)
+			if other == nil || !other.isSet() {
+				continue
+			}
+			if !hasValue {
+				c.fatal(errors.New(f.displayName() + " is required when " + name + " is set"))
+				return
+			}
+			if other.rv.CanInterface() && fmt.Sprint(other.rv.Interface()) == value {
+				c.fatal(errors.New(f.displayName() + " is required when " + cond))
+				return
+			}
+		}
+		if len(f.requiredUnless) == 0 {
+			continue
+		}
+		satisfied := false
+		for _, name := range f.requiredUnless {
+			if other := c.findFlag(name); other != nil && other.isSet() {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			c.fatal(errors.New(f.displayName() + " is required unless " +
+				strings.Join(f.requiredUnless, " or ") + " is given"))
+			return
+		}
+	}
+}
+
+// splitCondition splits a condition like --tls=true
+func splitCondition(cond string) (name, value string, hasValue bool) {
+	if i := strings.Index(cond, "="); i > 0 {
+		return cond[:i], cond[i+1:], true
+	}
+	return cond, "", false
+}
+
+// findFlag returns the flag being parsed by its long or short name
+func (c *Cortana) findFlag(name string) *flag {
+	for _, f := range c.parsing.flags {
+		if f.long == name || f.short == name {
+			return f
+		}
+	}
+	return nil
 }
 
 // unmarshalArgs fills v with the parsed args
-func (c *Cortana) unmarshalArgs(ignoreUnknown bool, onUsage func(usage string)) {
-	flags := buildArgsIndex(c.parsing.flags)
+func (c *Cortana) unmarshalArgs(opt *parseOption) {
+	flags := buildArgsIndex(c.parsing.flags, c.foldFlag)
 	nonflags := c.parsing.nonflags
 
 	var unknown []string
+	var positional bool // a positional arg has been seen
+	var endOfFlags bool // "--" has been seen
+	var rest bool       // a rest nonflag takes all the remaining args
+
+	// argSource returns the source of the arg at i, the leading args may come from
+	// the rc file
+	argSource := func(i int, detail string) Source {
+		if i < c.ctx.rcArgs {
+			return Source{Kind: SourceRCFile, Detail: c.rcfile}
+		}
+		return Source{Kind: SourceArg, Detail: detail}
+	}
+	// removeArgs removes the n args at i for a restart, the args from the rc file
+	// are counted in step
+	removeArgs := func(args []string, i, n int) {
+		c.ctx.args = append(args[0:i], args[i+n:]...)
+		if i < c.ctx.rcArgs {
+			if c.ctx.rcArgs-i < n {
+				n = c.ctx.rcArgs - i
+			}
+			c.ctx.rcArgs -= n
+		}
+	}
+
+	// applyNonflag applies the arg to the next nonflag, a slice or a joined string
+	// receives all the remaining ones. The values typed by the user replace the ones
+	// from the rc file
+	applyNonflag := func(arg string, source Source) {
+		nf := nonflags[0]
+		rv := nf.rv
+		if nf.hasJoin && rv.Kind() == reflect.String && nf.source.Kind == source.Kind {
+			arg = rv.String() + nf.join + arg
+		}
+		if isList(rv) && nf.source.Kind != source.Kind {
+			(*flag)(nf).resetList()
+		}
+		nf.source = source
+		if err := applyValue((*flag)(nf), rv, arg); err != nil {
+			c.fatal(err)
+		}
+		// an array is done once all of its elements are filled
+		if (!isList(rv) && !nf.hasJoin) || (rv.Kind() == reflect.Array && nf.filled == rv.Len()) {
+			nonflags = nonflags[1:]
+		}
+		// a rest nonflag takes all the args after its first one or the positional
+		// arg before it, like the program and its args
+		rest = len(nonflags) > 0 && nonflags[0].rest
+	}
+
+	for _, f := range c.parsing.flags {
+		f.occurrences = 0
+	}
 	args := c.ctx.args
 	for i := 0; i < len(args); i++ {
+		// the args after the first one of a rest nonflag are taken verbatim
+		if rest {
+			applyNonflag(args[i], argSource(i, ""))
+			continue
+		}
+		// the args after "--" are never flags, a "--" after the first positional in
+		// the stop mode is an arg of the wrapped command
+		if args[i] == "--" && !endOfFlags && !(positional && opt.stopAtFirstPositional) {
+			endOfFlags = true
+			continue
+		}
+		// the args after the first positional are never flags
+		if endOfFlags || (positional && opt.stopAtFirstPositional) {
+			if len(nonflags) == 0 {
+				unknown = append(unknown, args[i])
+				continue
+			}
+			applyNonflag(args[i], argSource(i, ""))
+			continue
+		}
 		// print the usage and abort
-		if args[i] == c.predefined.help.long || args[i] == c.predefined.help.short {
-			onUsage(c.UsageString())
+		help := c.parsing.help
+		if !opt.preview && args[i] != "" && (c.foldFlag(args[i]) == c.foldFlag(help.long) || args[i] == help.short) {
+			opt.onUsage(c.UsageString())
 			panic("abort")
 		}
+		// the first positional arg is left to Args() if there is no nonflag in stop mode
+		// a lone "-" has no name, it means stdin or stdout by convention
+		isValue := !strings.HasPrefix(args[i], "-") || args[i] == "-" || negativeValue(flags, nil, args[i])
+		if isValue && len(nonflags) == 0 && opt.stopAtFirstPositional {
+			positional = true
+			unknown = append(unknown, args[i])
+			continue
+		}
 		// handle nonflags
-		if !strings.HasPrefix(args[i], "-") && len(nonflags) > 0 {
-			rv := nonflags[0].rv
-			if err := applyValue(rv, args[i]); err != nil {
-				c.fatal(err)
-			}
-			if rv.Kind() != reflect.Slice {
-				nonflags = nonflags[1:]
+		if isValue && len(nonflags) > 0 {
+			positional = true
+			applyNonflag(args[i], argSource(i, ""))
+			continue
+		}
+
+		// split the combined short flags like -lah into -l -a -h
+		if shorts := c.splitShortFlags(flags, args[i]); shorts != nil {
+			expanded := append([]string{}, args[:i]...)
+			expanded = append(expanded, shorts...)
+			args = append(expanded, args[i+1:]...)
+			if i < c.ctx.rcArgs {
+				c.ctx.rcArgs += len(shorts) - 1
 			}
+			i--
 			continue
 		}
 
 		var emptyValue bool
 		var key, value string
-		if strings.Index(args[i], "=") > 0 {
+		// only a flag is split, a positional arg like FOO=bar is kept verbatim
+		if strings.HasPrefix(args[i], "-") && strings.Index(args[i], "=") > 0 {
 			kvs := strings.SplitN(args[i], "=", 2)
 			key, value = kvs[0], kvs[1]
 			// In case of --flag=, user set the flag as an empty value explicitly, the empty value should be allowd
@@ -778,67 +2187,277 @@ func (c *Cortana) unmarshalArgs(ignoreUnknown bool, onUsage func(usage string))
 		} else {
 			key = args[i]
 		}
+		key = c.foldFlag(key)
 
 		// handle the config flags
-		if key == c.predefined.cfg.long || key == c.predefined.cfg.short {
+		if key == c.foldFlag(c.predefined.cfg.long) || key == c.predefined.cfg.short {
 			cfg := c.configs[len(c.configs)-1] // overwrite the last one
 			cfg.requireExist = true
 			if value != "" {
 				cfg.path = value
-				c.ctx.args = append(args[0:i], args[i+1:]...)
+				removeArgs(args, i, 1)
 				panic("restart")
 			} else if i+1 < len(args) {
 				next := args[i+1]
-				if next[0] != '-' {
+				if !strings.HasPrefix(next, "-") {
 					cfg.path = args[i+1]
-					c.ctx.args = append(args[0:i], args[i+2:]...)
+					removeArgs(args, i, 2)
+					panic("restart")
+				}
+			}
+			c.fatal(errors.New(key + " requires an argument"))
+		}
+		// handle the profile flags
+		if key != "" && (key == c.foldFlag(c.predefined.profile.long) || key == c.predefined.profile.short) {
+			if value != "" {
+				c.profile = value
+				removeArgs(args, i, 1)
+				panic("restart")
+			} else if i+1 < len(args) {
+				next := args[i+1]
+				if !strings.HasPrefix(next, "-") {
+					c.profile = next
+					removeArgs(args, i, 2)
 					panic("restart")
 				}
 			}
 			c.fatal(errors.New(key + " requires an argument"))
 		}
 
-		flag, ok := flags[key]
+		// handle the output flags
+		if key != "" && (key == c.foldFlag(c.predefined.output.long) || key == c.predefined.output.short) {
+			if value == "" && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				value = args[i+1]
+				i++
+			}
+			if value == "" {
+				c.fatal(errors.New(key + " requires an argument"))
+				continue
+			}
+			if err := c.setOutput(value); err != nil {
+				c.fatal(err)
+			}
+			continue
+		}
+
+		// handle the dump config flags
+		if key != "" && (key == c.foldFlag(c.predefined.dump.long) || key == c.predefined.dump.short) {
+			c.parsing.dump = true
+			continue
+		}
+
+		flag, ok, err := c.lookupFlag(flags, key)
+		if err != nil {
+			c.fatal(err)
+			continue
+		}
 		if ok {
+			if containsString(flag.deprecated, key) && !c.parsing.warned[key] {
+				c.parsing.warned[key] = true
+				fmt.Fprintf(c.stderr, "warning: %s is deprecated, use %s\n", key, flag.displayName())
+			}
+			// a repeated flag is rejected or ignored by the policy, the value is still consumed.
+			// The first occurrence typed by the user overrides the ones from the rc file
+			source := argSource(i, key)
+			if flag.source.Kind == SourceRCFile && source.Kind == SourceArg {
+				flag.occurrences = 0
+			}
+			flag.occurrences++
+			rv := flag.rv
+			if flag.occurrences > 1 && !flag.accumulates() {
+				policy := opt.repeat
+				if flag.repeat != "" {
+					policy = repeatPolicies[flag.repeat]
+				}
+				switch policy {
+				case RepeatError:
+					c.fatal(errors.New("flag " + flag.displayName() + " specified multiple times"))
+					continue
+				case RepeatFirstWins:
+					rv = reflect.New(flag.rv.Type()).Elem()
+				}
+			}
+			// the first occurrence replaces the values of the other sources, the later ones
+			// accumulate. The values typed by the user replace the ones from the rc file
+			if isList(flag.rv) && flag.source.Kind != source.Kind {
+				flag.resetList()
+			}
+			flag.source = source
 			if emptyValue {
 				continue
 			}
+			// --no-cache sets --cache to false, and --no-cache=false sets it to true
+			if key != c.foldFlag(flag.long) && key == c.foldFlag(flag.negation()) {
+				if value == "" {
+					value = "true"
+				}
+				b, err := parseBool(value)
+				if err != nil {
+					c.fatal(err)
+					continue
+				}
+				if err := applyValue(flag, rv, strconv.FormatBool(!b)); err != nil {
+					c.fatal(err)
+				}
+				continue
+			}
 			if value != "" {
-				if err := applyValue(flag.rv, value); err != nil {
+				if err := applyValue(flag, rv, value); err != nil {
+					c.fatal(err)
+				}
+				continue
+			}
+			// the optional argument must be attached with '=', so the next arg is never consumed
+			if flag.hasOptArg {
+				if err := applyValue(flag, rv, flag.optArg); err != nil {
+					c.fatal(err)
+				}
+				continue
+			}
+			if flag.count {
+				if err := flag.increment(); err != nil {
 					c.fatal(err)
 				}
 				continue
 			}
-			if flag.rv.Kind() == reflect.Bool {
-				if err := applyValue(flag.rv, "true"); err != nil {
+			if flag.kind() == reflect.Bool {
+				if err := applyValue(flag, rv, "true"); err != nil {
 					c.fatal(err)
 				}
 				continue
 			}
 			if i+1 < len(args) {
 				next := args[i+1]
-				if next[0] != '-' || next == "--" { // allow "--" as a special value
-					if err := applyValue(flag.rv, next); err != nil {
+				// allow "--" and "-" as special values
+				if !strings.HasPrefix(next, "-") || next == "--" || next == "-" || negativeValue(flags, flag, next) {
+					if err := applyValue(flag, rv, next); err != nil {
 						c.fatal(err)
 					}
 					i++
+					// an array takes the following values until it is filled, like --size 800 600
+					for rv.Kind() == reflect.Array && flag.filled < rv.Len() && i+1 < len(args) &&
+						((args[i+1] != "" && args[i+1][0] != '-') || negativeValue(flags, flag, args[i+1])) {
+						if err := applyValue(flag, rv, args[i+1]); err != nil {
+							c.fatal(err)
+						}
+						i++
+					}
 					continue
 				}
 			}
 			c.fatal(errors.New(key + " requires an argument"))
 		} else {
-			if ignoreUnknown {
+			if opt.ignoreUnknownArgs {
 				unknown = append(unknown, args[i])
+				// keep the value of the unknown flag, so it is not taken as a positional arg
+				if strings.HasPrefix(key, "--") && key == args[i] && !containsString(opt.unknownBoolFlags, key) &&
+					i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+					unknown = append(unknown, args[i+1])
+					i++
+				}
 			} else {
 				c.fatal(errors.New("unknown argument: " + args[i]))
 			}
 		}
 	}
-	c.ctx.args = unknown
+	c.ctx.args, c.ctx.rcArgs = unknown, 0
+}
+
+// splitShortFlags splits the combined short flags like -lah, every rune must be a
+// bool or counting short flag until one taking a value, which takes the remaining
+// runes as the value, like -n5. It returns nil if the arg is a flag itself or can
+// not be split
+func (c *Cortana) splitShortFlags(flags map[string]*flag, arg string) []string {
+	if len(arg) < 3 || arg[0] != '-' || arg[1] == '-' || strings.Contains(arg, "=") {
+		return nil
+	}
+	if _, ok := flags[arg]; ok {
+		return nil
+	}
+	runes := []rune(arg[1:])
+	shorts := make([]string, 0, len(runes))
+	for i, r := range runes {
+		short := "-" + string(r)
+		f, ok := flags[short]
+		switch {
+		case short == c.predefined.help.short:
+		case !ok:
+			return nil
+		case f.takesValue() && i != len(runes)-1:
+			// the remaining runes are the value, like -n5 or -ofile.txt
+			return append(shorts, short+"="+string(runes[i+1:]))
+		}
+		shorts = append(shorts, short)
+	}
+	return shorts
+}
+
+// negativeValue reports if the arg is a negative number rather than a flag, like -5,
+// or a negative value of the numeric flag, like -1.5h. A flag named like -5 always wins
+func negativeValue(flags map[string]*flag, f *flag, arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	if _, ok := flags[arg]; ok {
+		return false
+	}
+	if _, err := strconv.ParseFloat(arg, 64); err == nil {
+		return true
+	}
+	if f == nil {
+		return false
+	}
+	switch f.kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64:
+		// a negative size like -4MiB is a value, so it is rejected by the flag
+		if f.rv.Type() == reflect.TypeOf(ByteSize(0)) {
+			_, err := parseByteSize(arg[1:])
+			return err == nil
+		}
+		// a prefixed integer out of the range like -0x81 for int8 is still a value, so
+		// the overflow is reported rather than a missing argument
+		if _, err := strconv.ParseInt(arg, intBase(arg), 64); err == nil {
+			return true
+		}
+		return applyValue(f, reflect.New(f.rv.Type()).Elem(), arg) == nil
+	}
+	return false
+}
+
+// lookupFlag finds the flag by the key typed in the args
+func (c *Cortana) lookupFlag(flags map[string]*flag, key string) (*flag, bool, error) {
+	if f, ok := flags[key]; ok {
+		return f, true, nil
+	}
+	// an unambiguous prefix of a long flag, like --verb for --verbose
+	if c.abbrevFlags && strings.HasPrefix(key, "--") && len(key) > 2 {
+		var matched []*flag
+		for _, f := range c.parsing.flags {
+			if strings.HasPrefix(c.foldFlag(f.long), key) {
+				matched = append(matched, f)
+			}
+		}
+		if len(matched) == 1 {
+			return matched[0], true, nil
+		}
+		if len(matched) > 1 {
+			var names []string
+			for _, f := range matched {
+				names = append(names, f.long)
+			}
+			return nil, false, fmt.Errorf("ambiguous flag %s (could be %s)", key, strings.Join(names, ", "))
+		}
+	}
+	return nil, false, nil
 }
 
-func (c *Cortana) unmarshalConfigs(v interface{}) {
+func (c *Cortana) unmarshalConfigs(vs []interface{}) {
+	var loaded []loadedConfig
 	for _, cfg := range c.configs {
+		if cfg.path != "" {
+			c.checkInterrupted("reading the config " + cfg.path)
+		}
 		file, err := os.Open(cfg.path)
 		if err != nil {
 			if os.IsNotExist(err) && !cfg.requireExist {
@@ -851,18 +2470,73 @@ func (c *Cortana) unmarshalConfigs(v interface{}) {
 			c.fatal(err)
 		}
 
-		if err := cfg.unmarshaler.Unmarshal(data, v); err != nil {
+		before := c.snapshot()
+		for _, v := range vs {
+			if err := unmarshalContext(c.parsing.ctx, cfg.unmarshaler, data, v); err != nil {
+				c.checkInterrupted("reading the config " + cfg.path)
+				c.fatal(err)
+			}
+		}
+		if err := c.applyConfigKeys(cfg, data); err != nil {
 			c.fatal(err)
 		}
+		c.recordChanges(before, Source{Kind: SourceConfig, Detail: cfg.path})
+		c.recordConfig(cfg.path, data)
 		file.Close()
+		loaded = append(loaded, loadedConfig{cfg: cfg, data: data})
+	}
+	if err := c.applyProfile(loaded); err != nil {
+		c.fatal(err)
 	}
 }
 
-func (c *Cortana) unmarshalEnvs(v interface{}) {
+func (c *Cortana) unmarshalEnvs(vs []interface{}) {
 	for _, u := range c.envs {
-		if err := u.Unmarshal(v); err != nil {
-			c.fatal(err)
+		c.checkInterrupted("unmarshaling the envs")
+		before := c.snapshot()
+		for _, v := range vs {
+			var err error
+			if cu, ok := u.(ContextEnvUnmarshaler); ok {
+				err = cu.UnmarshalContext(c.parsing.ctx, v)
+			} else {
+				err = u.Unmarshal(v)
+			}
+			if err != nil {
+				c.checkInterrupted("unmarshaling the envs")
+				c.fatal(err)
+			}
+		}
+		changed := c.recordChanges(before, Source{Kind: SourceEnv})
+		if namer, ok := u.(EnvNamer); ok {
+			for _, f := range changed {
+				f.source.Detail = namer.EnvName(f.path)
+			}
+		}
+	}
+	c.unmarshalEnvTags()
+}
+
+// unmarshalEnvTags applies the env variables named by the env tags, an empty but
+// set variable resets the flag to the zero value
+func (c *Cortana) unmarshalEnvTags() {
+	for _, f := range c.parsingFlags() {
+		if f.env == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if isList(f.rv) {
+			f.resetList()
+		} else if value == "" {
+			f.rv.Set(reflect.Zero(f.rv.Type()))
 		}
+		if err := applyValue(f, f.rv, value); err != nil {
+			c.fatal(fmt.Errorf("invalid value of env %s for %s: %v", f.env, f.displayName(), err))
+			continue
+		}
+		f.source = Source{Kind: SourceEnv, Detail: f.env}
 	}
 }
 
@@ -896,6 +2570,23 @@ func init() {
 	c = New()
 }
 
+// Default returns the commander used by the package level functions
+func Default() *Cortana {
+	return c
+}
+
+// Init rebuilds the default commander with the options, it should be called before
+// any command is added, otherwise an error is returned and the default commander
+// is kept. Unlike Use, which tweaks the existing commander, Init applies the
+// options to a brand new one like New does
+func Init(opts ...Option) error {
+	if c.seq > 0 {
+		return errors.New("cortana: Init must be called before adding any command")
+	}
+	c = New(opts...)
+	return nil
+}
+
 // Parse the arguemnts into a struct
 func Parse(v interface{}, opts ...ParseOption) {
 	c.Parse(v, opts...)
@@ -912,6 +2603,11 @@ func Description(text string) {
 	c.Description(text)
 }
 
+// Synopsis overrides the generated synopsis line of the usage for the command
+func Synopsis(text string) {
+	c.Synopsis(text)
+}
+
 // Usage prints the usage and exits
 func Usage() {
 	c.Usage()
@@ -922,14 +2618,39 @@ func Alias(name, definition string) {
 	c.Alias(name, definition)
 }
 
+// Profile returns the name of the active profile
+func Profile() string {
+	return c.Profile()
+}
+
 // Args returns the arguments for current command
 func Args() []string {
 	return c.Args()
 }
 
-// AddCommand adds a command
-func AddCommand(path string, cmd func(), brief string) {
-	c.AddCommand(path, cmd, brief)
+// AddCommand adds a command, it reports if a command of the same path is replaced
+func AddCommand(path string, cmd func(), brief string, opts ...CommandOption) bool {
+	return c.AddCommand(path, cmd, brief, opts...)
+}
+
+// AddCommandE adds a command whose handler returns an error
+func AddCommandE(path string, cmd func() error, brief string, opts ...CommandOption) bool {
+	return c.AddCommandE(path, cmd, brief, opts...)
+}
+
+// AddCommandArgs adds a command whose handler takes the args of the command
+func AddCommandArgs(path string, cmd func(args []string), brief string, opts ...CommandOption) bool {
+	return c.AddCommandArgs(path, cmd, brief, opts...)
+}
+
+// RemoveCommand removes the command of the path, and its sub commands if recursive
+func RemoveCommand(path string, recursive bool) bool {
+	return c.RemoveCommand(path, recursive)
+}
+
+// AddHiddenCommand adds a command which is runnable but not listed
+func AddHiddenCommand(path string, cmd func(), brief string, opts ...CommandOption) bool {
+	return c.AddHiddenCommand(path, cmd, brief, opts...)
 }
 
 // AddRootCommand adds the command without sub path
@@ -937,26 +2658,112 @@ func AddRootCommand(cmd func()) {
 	c.AddRootCommand(cmd)
 }
 
+// SetDefaultCommand runs the command of the path if the args match no command
+func SetDefaultCommand(path string) {
+	c.SetDefaultCommand(path)
+}
+
+// UseMiddleware registers the middlewares wrapping the execution of the commands
+func UseMiddleware(mws ...Middleware) {
+	c.UseMiddleware(mws...)
+}
+
+// PersistentFlags registers the struct of the flags parsed for every command
+func PersistentFlags(v interface{}) {
+	c.PersistentFlags(v)
+}
+
 // AddConfig adds a configuration file
 func AddConfig(path string, unmarshaler Unmarshaler) {
 	c.AddConfig(path, unmarshaler)
 }
 
-// Commands returns the list of the added commands
+// Flags returns the flags of the last parsing with the sources of their values
+func Flags() []FlagInfo {
+	return c.Flags()
+}
+
+// DefaultProvider computes the default value of the flag named by its long or short name
+func DefaultProvider(name string, provider func() (string, error)) {
+	c.DefaultProvider(name, provider)
+}
+
+// SetNotFoundHandler handles the args which match no command
+func SetNotFoundHandler(handler func(args []string) error) {
+	c.SetNotFoundHandler(handler)
+}
+
+// Commands returns the list of the added commands, the hidden ones are excluded
 func Commands() []*Command {
 	return c.Commands()
 }
 
+// AllCommands returns the list of the added commands including the hidden ones
+func AllCommands() []*Command {
+	return c.AllCommands()
+}
+
 // Launch finds and executes the command, os.Args is used if no args supplied
 func Launch(args ...string) {
 	c.Launch(args...)
 }
 
-// Use the cortana options
+// LaunchE finds and executes the command like Launch but returns the error
+func LaunchE(args ...string) error {
+	return c.LaunchE(args...)
+}
+
+// LaunchContext runs the command like LaunchE with the ctx for the preflight checks
+func LaunchContext(ctx stdctx.Context, args ...string) error {
+	return c.LaunchContext(ctx, args...)
+}
+
+// Fatal reports the error the same way as the failures of cortana
+func Fatal(err error) {
+	c.Fatal(err)
+}
+
+// Fatalf formats the error and reports it like Fatal
+func Fatalf(format string, a ...interface{}) {
+	c.Fatalf(format, a...)
+}
+
+// Use the cortana options on the default commander, the options which only take
+// effect when constructing a commander should be passed to Init instead
 func Use(opts ...Option) {
 	c.Use(opts...)
 }
 
+// LoadSpec adds the commands described by the json spec
+func LoadSpec(data []byte, handler func(path string, flags DynamicFlags, args []string) error) error {
+	return c.LoadSpec(data, handler)
+}
+
+// Replay launches the recorded invocation again
+func Replay(r *Record) {
+	c.Replay(r)
+}
+
+// ParseContext parses the flags, it is aborted once the ctx is done
+func ParseContext(ctx stdctx.Context, v interface{}, opts ...ParseOption) {
+	c.ParseContext(ctx, v, opts...)
+}
+
+// BeginParse starts a staged parse of several structs
+func BeginParse() *StagedParse {
+	return c.BeginParse()
+}
+
+// Print writes v to the stdout in the format selected by the output flag
+func Print(v interface{}) {
+	c.Print(v)
+}
+
+// CompleteFlag returns the candidate values with the prefix for the flag
+func CompleteFlag(name, prefix string) []string {
+	return c.CompleteFlag(name, prefix)
+}
+
 // Complete returns all the commands that has prefix
 func Complete(prefix string) []*Command {
 	return c.Complete(prefix)
@@ -967,7 +2774,17 @@ func SearchCommand(args []string) *Command {
 	return c.SearchCommand(args)
 }
 
+// Resolve returns the command the args would run without running it
+func Resolve(args []string) *Resolution {
+	return c.Resolve(args)
+}
+
 // Usage returns the usage string
 func UsageString() string {
 	return c.UsageString()
 }
+
+// UsageOf returns the usage of the command without executing it
+func UsageOf(path string) (string, error) {
+	return c.UsageOf(path)
+}
@@ -0,0 +1,1014 @@
+package cortana
+
+import (
+	"bytes"
+	stdctx "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseArgs parses the args into v and returns what is reported to stderr, the
+// errors are reported instead of exiting the process
+func parseArgs(t *testing.T, v interface{}, args ...string) string {
+	t.Helper()
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	c.Parse(v, WithArgs(append([]string{}, args...)))
+	return stderr.String()
+}
+
+func TestIntOverflow(t *testing.T) {
+	cases := []struct {
+		name string
+		v    interface{}
+		args []string
+	}{
+		{"int8", &struct {
+			Level int8 `cortana:"--level, -l, 3, level"`
+		}{}, []string{"--level", "300"}},
+		{"negative int8", &struct {
+			Level int8 `cortana:"--level, -l, 3, level"`
+		}{}, []string{"--level", "-129"}},
+		{"uint8", &struct {
+			Level uint8 `cortana:"--level, -l, 3, level"`
+		}{}, []string{"--level", "256"}},
+		{"float32", &struct {
+			Level float32 `cortana:"--level, -l, 3, level"`
+		}{}, []string{"--level", "1e40"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := parseArgs(t, c.v, c.args...)
+			if !strings.Contains(msg, `invalid value "`+c.args[1]+`" for --level`) || !strings.Contains(msg, "out of range") {
+				t.Errorf("unexpected error %q", msg)
+			}
+		})
+	}
+
+	opts := struct {
+		Level int8 `cortana:"--level, -l, 3, level"`
+	}{}
+	if msg := parseArgs(t, &opts, "--level", "127"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if opts.Level != 127 {
+		t.Errorf("expected 127, got %d", opts.Level)
+	}
+}
+
+func TestParseNonPointer(t *testing.T) {
+	opts := struct {
+		Name string `cortana:"--name, -n, , name"`
+	}{}
+	for _, v := range []interface{}{opts, &opts.Name, (*struct{})(nil), 1} {
+		msg := parseArgs(t, v, "--name", "x")
+		if !strings.Contains(msg, "Parse requires a pointer to a struct") {
+			t.Errorf("%T: unexpected error %q", v, msg)
+		}
+	}
+}
+
+func TestParseUnexportedField(t *testing.T) {
+	opts := struct {
+		Name  string `cortana:"--name, -n, , name"`
+		level int    `cortana:"--level, -l, 3, level"`
+	}{}
+	msg := parseArgs(t, &opts, "--name", "x")
+	if !strings.Contains(msg, "warning: field level is unexported") {
+		t.Errorf("expected a warning of the unexported field, got %q", msg)
+	}
+	if opts.Name != "x" || opts.level != 0 {
+		t.Errorf("unexpected values %+v", opts)
+	}
+
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr), StrictTags())
+	c.Parse(&opts, WithArgs([]string{}))
+	if !strings.Contains(stderr.String(), "field level is unexported and can not be set") {
+		t.Errorf("expected an error in the strict mode, got %q", stderr.String())
+	}
+}
+
+func TestApplyValueUnsettable(t *testing.T) {
+	v := reflect.ValueOf(struct{ Level int }{}).Field(0)
+	f := &flag{long: "--level", path: "Options.Level", rv: v}
+	err := applyValue(f, v, "1")
+	if err == nil || !strings.Contains(err.Error(), "field Options.Level can not be set") {
+		t.Errorf("expected the error naming the field, got %v", err)
+	}
+}
+
+// pathological is a struct with the fields which are hard to parse
+type pathological struct {
+	Int      int8              `cortana:"--int, -i, 0, int"`
+	Uint     uint16            `cortana:"--uint, -u, 0, uint"`
+	Float    float32           `cortana:"--float, -f, 0, float"`
+	Bool     bool              `cortana:"--bool, -b, false, bool"`
+	Duration time.Duration     `cortana:"--duration, -d, 0s, duration"`
+	List     []*int            `cortana:"--list, -l, , list"`
+	Array    [2]int            `cortana:"--array, -a, , array"`
+	Map      map[string]int    `cortana:"--map, -m, , map"`
+	Bytes    []byte            `cortana:"--bytes, -, , bytes" encoding:"hex"`
+	JSON     map[string]string `cortana:"--json, -, , json" format:"json"`
+	Ptr      *string           `cortana:"--ptr, -p, , pointer"`
+	Nested   struct {
+		Name string `cortana:"--name, -n, , name"`
+	}
+	unexported string   `cortana:"--unexported, -, , unexported"`
+	Args       []string `cortana:"args, -, , args"`
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"-i 300",
+		"--int=-129 --uint 0x10",
+		"-bi5 --float 1e40",
+		"--list 1 --list x --array 1 2 3",
+		"--map a=1 --map b --map =",
+		"--bytes zz --json {",
+		"--ptr -- --int",
+		"--duration 1h -n",
+		"a b -- -c",
+		"-i  --list  --profile ",
+	} {
+		f.Add(seed)
+	}
+	// the args are split by a single space, so the empty ones are fuzzed as well
+	f.Fuzz(func(t *testing.T, s string) {
+		var opts pathological
+		c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard), ProfileFlag("--profile", "-P"))
+		c.Parse(&opts, WithArgs(strings.Split(s, " ")), OnUsage(func(string) {}))
+	})
+}
+
+func TestStopAtFirstPositional(t *testing.T) {
+	opts := struct {
+		Verbose bool     `cortana:"--verbose, -v, false, verbose"`
+		Name    string   `cortana:"--name, -n, , name"`
+		Binary  string   `cortana:"binary, -, -, the binary to run"`
+		Args    []string `cortana:"args, -, , the args of the binary"`
+	}{}
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	// the args of the wrapped binary collide with ours, even --help and --
+	c.Parse(&opts, StopAtFirstPositional(), WithArgs([]string{
+		"-v", "--name", "run", "ls", "-v", "--name", "x", "--help", "--", "-n"}))
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	if !opts.Verbose || opts.Name != "run" || opts.Binary != "ls" {
+		t.Errorf("unexpected values %+v", opts)
+	}
+	want := []string{"-v", "--name", "x", "--help", "--", "-n"}
+	if !reflect.DeepEqual(opts.Args, want) {
+		t.Errorf("expected the args %q, got %q", want, opts.Args)
+	}
+
+	// without a nonflag, the args after the first positional are left to Args
+	stderr.Reset()
+	var flags struct {
+		Verbose bool `cortana:"--verbose, -v, false, verbose"`
+	}
+	c.Parse(&flags, StopAtFirstPositional(), WithArgs([]string{"ls", "-v", "-l"}))
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	if flags.Verbose || !reflect.DeepEqual(c.Args(), []string{"ls", "-v", "-l"}) {
+		t.Errorf("unexpected values %+v and args %q", flags, c.Args())
+	}
+}
+
+// rule is a TextUnmarshaler like "allow:80"
+type rule struct {
+	Action string
+	Port   int
+}
+
+func (r *rule) UnmarshalText(text []byte) error {
+	kv := strings.SplitN(string(text), ":", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected action:port, got %q", text)
+	}
+	port, err := strconv.Atoi(kv[1])
+	if err != nil {
+		return err
+	}
+	r.Action, r.Port = kv[0], port
+	return nil
+}
+
+func TestPointerElements(t *testing.T) {
+	opts := struct {
+		Ports   []*int    `cortana:"--port, -p, , ports"`
+		Include []*string `cortana:"--include, -i, , includes"`
+		Rules   []*rule   `cortana:"--rule, -r, , rules"`
+	}{}
+	msg := parseArgs(t, &opts, "-p", "80", "-p", "443", "-i", "a", "-i", "", "-r", "allow:80", "-r", "deny:22")
+	if msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	var ports []int
+	for _, p := range opts.Ports {
+		ports = append(ports, *p)
+	}
+	if !reflect.DeepEqual(ports, []int{80, 443}) {
+		t.Errorf("expected the ports [80 443], got %v", ports)
+	}
+	if len(opts.Include) != 1 || *opts.Include[0] != "a" {
+		t.Errorf("expected the includes [a], got %v", opts.Include)
+	}
+	if len(opts.Rules) != 2 || *opts.Rules[0] != (rule{"allow", 80}) || *opts.Rules[1] != (rule{"deny", 22}) {
+		t.Errorf("unexpected rules %v", opts.Rules)
+	}
+
+	msg = parseArgs(t, &opts, "-r", "allow")
+	if !strings.Contains(msg, `invalid value "allow" for --rule`) {
+		t.Errorf("unexpected error %q", msg)
+	}
+}
+
+func TestJoinedText(t *testing.T) {
+	type note struct {
+		Priority string `cortana:"--priority, -p, normal, priority"`
+		Done     bool   `cortana:"--done, -d, false, done"`
+		Text     string `cortana:"text, -, -, the note" join:" "`
+	}
+	cases := []struct {
+		args []string
+		want note
+	}{
+		{[]string{"buy", "milk", "tomorrow"}, note{"normal", false, "buy milk tomorrow"}},
+		{[]string{"buy", "milk", "-p", "high", "tomorrow"}, note{"high", false, "buy milk tomorrow"}},
+		{[]string{"buy", "milk", "--done", "tomorrow", "--priority=low"}, note{"low", true, "buy milk tomorrow"}},
+		{[]string{"-d", "buy", "--", "-p", "high"}, note{"normal", true, "buy -p high"}},
+	}
+	for _, c := range cases {
+		var opts note
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts != c.want {
+			t.Errorf("%q: expected %+v, got %+v", c.args, c.want, opts)
+		}
+	}
+}
+
+func TestUnknownArgsRoundTrip(t *testing.T) {
+	type first struct {
+		Name string `cortana:"--name, -n, , name"`
+		File string `cortana:"file, -, , file"`
+	}
+	type second struct {
+		Foo     string `cortana:"--foo, -, , foo"`
+		Verbose bool   `cortana:"--verbose, -, false, verbose"`
+		Level   int    `cortana:"--level, -, 0, level"`
+	}
+	cases := []struct {
+		name     string
+		args     []string
+		bools    []string
+		file     string
+		leftover []string
+		want     second
+	}{
+		{"value owned", []string{"--foo", "bar", "-n", "x", "a.txt"}, nil,
+			"a.txt", []string{"--foo", "bar"}, second{Foo: "bar"}},
+		{"key=value", []string{"--level=3", "a.txt", "--foo", "bar"}, nil,
+			"a.txt", []string{"--level=3", "--foo", "bar"}, second{Foo: "bar", Level: 3}},
+		{"bool declared", []string{"--verbose", "a.txt", "--foo", "bar"}, []string{"--verbose"},
+			"a.txt", []string{"--verbose", "--foo", "bar"}, second{Foo: "bar", Verbose: true}},
+		{"flag after flag", []string{"--verbose", "--level", "2", "a.txt"}, nil,
+			"a.txt", []string{"--verbose", "--level", "2"}, second{Verbose: true, Level: 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr := bytes.NewBuffer(nil)
+			c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+			var f first
+			c.Parse(&f, WithArgs(tc.args), IgnoreUnknownArgs(), UnknownBoolFlags(tc.bools...))
+			if f.File != tc.file {
+				t.Errorf("expected the file %q, got %q", tc.file, f.File)
+			}
+			if !reflect.DeepEqual(c.Args(), tc.leftover) {
+				t.Errorf("expected the leftovers %q, got %q", tc.leftover, c.Args())
+			}
+			// the leftovers are parsed by the second struct as they are
+			var s second
+			c.Parse(&s)
+			if stderr.Len() > 0 {
+				t.Fatalf("unexpected error %q", stderr.String())
+			}
+			if s != tc.want {
+				t.Errorf("expected %+v, got %+v", tc.want, s)
+			}
+		})
+	}
+}
+
+func TestCombinedShortFlags(t *testing.T) {
+	type ls struct {
+		Long   bool   `cortana:"--long, -l, false, long listing"`
+		All    bool   `cortana:"--all, -a, false, all files"`
+		Human  bool   `cortana:"--human, -h, false, human readable"`
+		Verify bool   `cortana:"--verify, -v, false, verify"`
+		Number int    `cortana:"--number, -n, 0, number"`
+		ABC    bool   `cortana:"--abc, -abc, false, a multi-char short"`
+		Path   string `cortana:"path, -, , path"`
+	}
+	cases := []struct {
+		args []string
+		want ls
+	}{
+		{[]string{"-lah"}, ls{Long: true, All: true, Human: true}},
+		{[]string{"-la", "-h", "dir"}, ls{Long: true, All: true, Human: true, Path: "dir"}},
+		{[]string{"-vn", "5"}, ls{Verify: true, Number: 5}},
+		{[]string{"-vn", "5", "dir"}, ls{Verify: true, Number: 5, Path: "dir"}},
+		{[]string{"-lvn", "-3"}, ls{Long: true, Verify: true, Number: -3}},
+		// a registered multi-char short is never split
+		{[]string{"-abc"}, ls{ABC: true}},
+	}
+	for _, c := range cases {
+		var opts ls
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts != c.want {
+			t.Errorf("%q: expected %+v, got %+v", c.args, c.want, opts)
+		}
+	}
+
+	// the whole token falls back if any rune is not a short flag
+	var opts ls
+	if msg := parseArgs(t, &opts, "-lax"); !strings.Contains(msg, "unknown argument: -lax") {
+		t.Errorf("expected -lax to be unknown, got %q", msg)
+	}
+	if opts.Long || opts.All {
+		t.Errorf("expected no flag set by -lax, got %+v", opts)
+	}
+	// a value flag in the middle takes the remaining runes as its value
+	if msg := parseArgs(t, &opts, "-nla"); !strings.Contains(msg, `invalid value "la" for --number`) {
+		t.Errorf("expected -nla to be rejected, got %q", msg)
+	}
+	// a value flag at the end still requires its argument
+	if msg := parseArgs(t, &opts, "-vn"); !strings.Contains(msg, "-n requires an argument") {
+		t.Errorf("expected -vn to require an argument, got %q", msg)
+	}
+}
+
+func TestHiddenFlag(t *testing.T) {
+	type options struct {
+		Verbose    bool   `cortana:"--verbose, -v, false, verbose"`
+		SkipVerify bool   `cortana:"--unsafe-skip-verify, -, false, skip the verification" hidden:"true"`
+		Addr       string `cortana:"--profile-addr, -, , the address of the profiler" hidden:"true" env:"CORTANA_TEST_PROFILE_ADDR"`
+	}
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	var opts options
+	c.Parse(&opts, WithArgs([]string{}))
+	usage := c.UsageString()
+	if !strings.Contains(usage, "--verbose") {
+		t.Errorf("expected --verbose in the usage %q", usage)
+	}
+	for _, name := range []string{"--unsafe-skip-verify", "--profile-addr"} {
+		if strings.Contains(usage, name) {
+			t.Errorf("expected %s absent from the usage %q", name, usage)
+		}
+	}
+
+	// args
+	opts = options{}
+	c.Parse(&opts, WithArgs([]string{"--unsafe-skip-verify", "--profile-addr", ":6060"}))
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	if !opts.SkipVerify || opts.Addr != ":6060" {
+		t.Errorf("expected the hidden flags set by the args, got %+v", opts)
+	}
+
+	// config
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{"SkipVerify": true, "Addr": ":7070"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c = New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	c.AddConfig(cfg, UnmarshalFunc(json.Unmarshal))
+	opts = options{}
+	c.Parse(&opts, WithArgs([]string{}))
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	if !opts.SkipVerify || opts.Addr != ":7070" {
+		t.Errorf("expected the hidden flags set by the config, got %+v", opts)
+	}
+
+	// env
+	t.Setenv("CORTANA_TEST_PROFILE_ADDR", ":8080")
+	c = New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	opts = options{}
+	c.Parse(&opts, WithArgs([]string{}))
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	if opts.Addr != ":8080" {
+		t.Errorf("expected the hidden flag set by the env, got %+v", opts)
+	}
+}
+
+func TestSliceDefaults(t *testing.T) {
+	type withDefault struct {
+		Tags []string `cortana:"--tag, -t, web, tags"`
+	}
+	type withoutDefault struct {
+		Tags []string `cortana:"--tag, -t, , tags"`
+	}
+	type nilDefault struct {
+		Tags []string `cortana:"--tag, -t, nil, tags"`
+	}
+	cases := []struct {
+		name string
+		v    interface{}
+		args []string
+		want []string
+	}{
+		{"default only", &withDefault{}, nil, []string{"web"}},
+		{"args only", &withoutDefault{}, []string{"--tag", "api"}, []string{"api"}},
+		{"default and args", &withDefault{}, []string{"--tag", "api"}, []string{"api"}},
+		{"default and repeated args", &withDefault{}, []string{"-t", "api", "--tag", "db"}, []string{"api", "db"}},
+		{"nil default", &nilDefault{}, nil, nil},
+		{"nil default and args", &nilDefault{}, []string{"-t", "api"}, []string{"api"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if msg := parseArgs(t, tc.v, tc.args...); msg != "" {
+				t.Fatalf("unexpected error %q", msg)
+			}
+			got := reflect.ValueOf(tc.v).Elem().Field(0).Interface().([]string)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+
+	// the values of the env replace the default as well
+	t.Setenv("CORTANA_TEST_TAGS", "api")
+	var opts struct {
+		Tags []string `cortana:"--tag, -t, web, tags" env:"CORTANA_TEST_TAGS"`
+	}
+	if msg := parseArgs(t, &opts); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if !reflect.DeepEqual(opts.Tags, []string{"api"}) {
+		t.Errorf("expected the tags from the env, got %q", opts.Tags)
+	}
+	if msg := parseArgs(t, &opts, "-t", "db"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if !reflect.DeepEqual(opts.Tags, []string{"db"}) {
+		t.Errorf("expected the tags from the args, got %q", opts.Tags)
+	}
+}
+
+func TestGluedShortValue(t *testing.T) {
+	type options struct {
+		Verbose bool   `cortana:"--verbose, -v, false, verbose"`
+		Number  int    `cortana:"--number, -n, 0, number"`
+		Output  string `cortana:"--output, -o, , output"`
+	}
+	cases := []struct {
+		args []string
+		want options
+	}{
+		{[]string{"-n5"}, options{Number: 5}},
+		{[]string{"-n-5"}, options{Number: -5}},
+		{[]string{"-ofoo.txt"}, options{Output: "foo.txt"}},
+		{[]string{"-vofoo.txt", "-n10"}, options{Verbose: true, Number: 10, Output: "foo.txt"}},
+	}
+	for _, c := range cases {
+		var opts options
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts != c.want {
+			t.Errorf("%q: expected %+v, got %+v", c.args, c.want, opts)
+		}
+	}
+
+	// a bool short never swallows a suffix
+	var opts options
+	if msg := parseArgs(t, &opts, "-vx"); !strings.Contains(msg, "unknown argument: -vx") {
+		t.Errorf("expected -vx to be unknown, got %q", msg)
+	}
+
+	// a registered -n5 is matched exactly rather than -n with the value 5
+	var exact struct {
+		Number int  `cortana:"--number, -n, 0, number"`
+		N5     bool `cortana:"--n5, -n5, false, the n5 mode"`
+	}
+	if msg := parseArgs(t, &exact, "-n5"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if !exact.N5 || exact.Number != 0 {
+		t.Errorf("expected -n5 matched exactly, got %+v", exact)
+	}
+	exact.N5 = false
+	if msg := parseArgs(t, &exact, "-n6"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if exact.N5 || exact.Number != 6 {
+		t.Errorf("expected -n6 to set the number, got %+v", exact)
+	}
+}
+
+func TestPositionalVerbatim(t *testing.T) {
+	type options struct {
+		Force bool   `cortana:"--force, -f, false, force"`
+		Key   string `cortana:"key, -, , the key"`
+		Value string `cortana:"value, -, , the value"`
+	}
+	cases := []struct {
+		args []string
+		want options
+	}{
+		{[]string{"FOO=bar"}, options{Key: "FOO=bar"}},
+		{[]string{"FOO=bar", "x=y=z"}, options{Key: "FOO=bar", Value: "x=y=z"}},
+		{[]string{"-f", "FOO="}, options{Force: true, Key: "FOO="}},
+		{[]string{"=bar"}, options{Key: "=bar"}},
+		{[]string{"a--b", "x=--y"}, options{Key: "a--b", Value: "x=--y"}},
+		{[]string{" -f", "  FOO=bar"}, options{Key: " -f", Value: "  FOO=bar"}},
+		{[]string{"--force=true", "FOO=bar"}, options{Force: true, Key: "FOO=bar"}},
+	}
+	for _, c := range cases {
+		var opts options
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts != c.want {
+			t.Errorf("%q: expected %+v, got %+v", c.args, c.want, opts)
+		}
+	}
+}
+
+func TestDashValue(t *testing.T) {
+	type options struct {
+		Input   string `cortana:"--input, -i, , input"`
+		Verbose bool   `cortana:"--verbose, -v, false, verbose"`
+		Source  File   `cortana:"source, -, , the source file"`
+		Dest    File   `cortana:"--dest, -d, , the destination" mode:"w"`
+	}
+	cases := []struct {
+		args  []string
+		input string
+		src   string
+	}{
+		{[]string{"--input", "-"}, "-", ""},
+		{[]string{"-i", "-", "-"}, "-", "-"},
+		{[]string{"--input=-"}, "-", ""},
+		{[]string{"-"}, "", "-"},
+		{[]string{"-v", "-", "--input", "-"}, "-", "-"},
+		{[]string{"--", "-"}, "", "-"},
+	}
+	for _, c := range cases {
+		var opts options
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts.Input != c.input || opts.Source.Path != c.src {
+			t.Errorf("%q: expected the input %q and the source %q, got %+v", c.args, c.input, c.src, opts)
+		}
+	}
+
+	// "-" means stdin, or stdout with mode:"w"
+	var opts options
+	if msg := parseArgs(t, &opts, "-", "--dest", "-"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if fd, err := opts.Source.Open(); err != nil || fd != os.Stdin {
+		t.Errorf("expected stdin for the source, got %v, %v", fd, err)
+	}
+	if fd, err := opts.Dest.Open(); err != nil || fd != os.Stdout {
+		t.Errorf("expected stdout for the destination, got %v, %v", fd, err)
+	}
+}
+
+func TestIntBase(t *testing.T) {
+	type options struct {
+		Offset int    `cortana:"--offset, -, 0, offset"`
+		Small  int8   `cortana:"--small, -, 0, small"`
+		Mask   uint16 `cortana:"--mask, -, 0x0f, mask"`
+		Byte   uint8  `cortana:"--byte, -, 0, byte"`
+	}
+	cases := []struct {
+		args []string
+		want options
+	}{
+		{nil, options{Mask: 0x0f}},
+		{[]string{"--mask", "0xff00"}, options{Mask: 0xff00}},
+		{[]string{"--mask", "0XFF"}, options{Mask: 0xff}},
+		{[]string{"--byte", "0b1010", "--offset", "0o17"}, options{Mask: 0x0f, Byte: 10, Offset: 15}},
+		{[]string{"--offset", "-0x10"}, options{Mask: 0x0f, Offset: -16}},
+		{[]string{"--offset=-0b11"}, options{Mask: 0x0f, Offset: -3}},
+		{[]string{"--small", "-0x80"}, options{Mask: 0x0f, Small: -128}},
+		// a plain leading zero is decimal rather than octal
+		{[]string{"--offset", "007", "--byte", "010"}, options{Mask: 0x0f, Offset: 7, Byte: 10}},
+		{[]string{"--offset", "-010"}, options{Mask: 0x0f, Offset: -10}},
+	}
+	for _, c := range cases {
+		var opts options
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts != c.want {
+			t.Errorf("%q: expected %+v, got %+v", c.args, c.want, opts)
+		}
+	}
+
+	errs := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--small", "-0x81"}, `invalid value "-0x81" for --small`},
+		{[]string{"--small", "0x80"}, `invalid value "0x80" for --small`},
+		{[]string{"--byte", "0x100"}, `invalid value "0x100" for --byte`},
+		{[]string{"--byte=-0x1"}, `invalid value "-0x1" for --byte`},
+		{[]string{"--offset", "0x"}, `invalid value "0x" for --offset`},
+		{[]string{"--byte", "0b102"}, `invalid value "0b102" for --byte`},
+		{[]string{"--offset", "08x"}, `invalid value "08x" for --offset`},
+	}
+	for _, e := range errs {
+		var opts options
+		if msg := parseArgs(t, &opts, e.args...); !strings.Contains(msg, e.want) {
+			t.Errorf("%q: expected the error %q, got %q", e.args, e.want, msg)
+		}
+	}
+}
+
+func TestExpandAlias(t *testing.T) {
+	cases := []struct {
+		definition string
+		args       []string
+		want       []string
+	}{
+		{"files copy --from $1 --to $2", []string{"a", "b"}, []string{"files", "copy", "--from", "a", "--to", "b"}},
+		{"files copy --to $2 --from $1", []string{"a", "b"}, []string{"files", "copy", "--to", "b", "--from", "a"}},
+		{"files copy --from $1", []string{"a", "b", "-v"}, []string{"files", "copy", "--from", "a", "b", "-v"}},
+		{"run $1 -- $@", []string{"ls", "-l", "-a"}, []string{"run", "ls", "--", "-l", "-a"}},
+		{"run $@ --verbose", []string{"ls", "-l"}, []string{"run", "ls", "-l", "--verbose"}},
+		{"run $@", nil, []string{"run"}},
+		{"files copy --from=$1 --to=$2", []string{"a", "b"}, []string{"files", "copy", "--from=a", "--to=b"}},
+		{"echo --msg=$@", []string{"hello", "world"}, []string{"echo", "--msg=hello world"}},
+		{"echo $1$1", []string{"ha"}, []string{"echo", "haha"}},
+		{"echo $0", []string{"a"}, []string{"echo", "$0", "a"}},
+		// the quoted args keep their spaces
+		{"files copy --from $1 --to $2", []string{"my file.txt", "your file.txt"},
+			[]string{"files", "copy", "--from", "my file.txt", "--to", "your file.txt"}},
+		{"git commit -m $1 $@", []string{"fix the bug", "--amend", "a b"},
+			[]string{"git", "commit", "-m", "fix the bug", "--amend", "a b"}},
+		// the quotes of the definition are stripped
+		{`say "hello world" $1`, []string{"cortana"}, []string{"say", "hello world", "cortana"}},
+		{`echo --msg="a b" "" x`, nil, []string{"echo", "--msg=a b", "", "x"}},
+		{`echo  "  spaced  "  `, nil, []string{"echo", "  spaced  "}},
+	}
+	for _, c := range cases {
+		got, err := expandAlias(c.definition, c.args)
+		if err != nil {
+			t.Errorf("%s %q: %v", c.definition, c.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s %q: expected %q, got %q", c.definition, c.args, c.want, got)
+		}
+	}
+
+	// the highest placeholder without an arg is named
+	errs := []struct {
+		definition string
+		args       []string
+		want       string
+	}{
+		{"files copy --from $1 --to $2", []string{"a"}, `no arg for $2, "files copy --from $1 --to $2" takes 2 args, got 1`},
+		{"x $1 $3", []string{"a"}, `no arg for $3, "x $1 $3" takes 3 args, got 1`},
+		{"x $2 $1", nil, `no arg for $2, "x $2 $1" takes 2 args, got 0`},
+	}
+	for _, e := range errs {
+		_, err := expandAlias(e.definition, e.args)
+		if err == nil || err.Error() != e.want {
+			t.Errorf("%s %q: expected the error %q, got %v", e.definition, e.args, e.want, err)
+		}
+	}
+}
+
+func TestAliasPlaceholders(t *testing.T) {
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	var opts struct {
+		From string `cortana:"--from, -f, , from"`
+		To   string `cortana:"--to, -t, , to"`
+	}
+	c.AddCommand("files copy", func() {
+		c.Parse(&opts)
+	}, "copy the files")
+	c.Alias("cp", "files copy --from $1 --to $2")
+
+	if err := c.LaunchE("cp", "my file.txt", "your file.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() > 0 {
+		t.Fatalf("unexpected error %q", stderr.String())
+	}
+	if opts.From != "my file.txt" || opts.To != "your file.txt" {
+		t.Errorf("unexpected values %+v", opts)
+	}
+
+	err := c.LaunchE("cp", "a")
+	want := `alias cp: no arg for $2, "files copy --from $1 --to $2" takes 2 args, got 1`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected the error %q, got %v", want, err)
+	}
+}
+
+// parseWithConfig parses the args with the --config flag, the config file of the
+// content is named c.json in the working directory of the test
+func parseWithConfig(t *testing.T, v interface{}, content string, args ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "c.json"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i := range args {
+		args[i] = strings.ReplaceAll(args[i], "c.json", filepath.Join(dir, "c.json"))
+	}
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr),
+		ConfFlag("--config", "-c", UnmarshalFunc(json.Unmarshal)))
+	c.Parse(v, WithArgs(args))
+	return stderr.String()
+}
+
+func TestRestartCount(t *testing.T) {
+	var opts struct {
+		Verbose int `cortana:"--verbose, -v, 0, verbosity" count:""`
+	}
+	// the --config flag restarts the parse, the args before it are counted once
+	if msg := parseWithConfig(t, &opts, `{}`, "-v", "-v", "--config", "c.json", "-v"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if opts.Verbose != 3 {
+		t.Errorf("expected the verbosity 3, got %d", opts.Verbose)
+	}
+}
+
+func TestRestartArray(t *testing.T) {
+	var opts struct {
+		Size [2]int `cortana:"--size, -s, , width and height"`
+	}
+	if msg := parseWithConfig(t, &opts, `{}`, "--size", "1", "2", "--config", "c.json"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if opts.Size != [2]int{1, 2} {
+		t.Errorf("expected the size [1 2], got %v", opts.Size)
+	}
+}
+
+func TestRestartJoin(t *testing.T) {
+	var opts struct {
+		Text string `cortana:"text, -, -, the note" join:" "`
+	}
+	if msg := parseWithConfig(t, &opts, `{}`, "buy", "milk", "--config", "c.json", "tomorrow"); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if opts.Text != "buy milk tomorrow" {
+		t.Errorf("expected the text %q, got %q", "buy milk tomorrow", opts.Text)
+	}
+}
+
+func TestRestartList(t *testing.T) {
+	var opts struct {
+		Tags  []string `cortana:"--tag, -t, web, tags"`
+		Hosts []string `cortana:"--host, -, , hosts"`
+	}
+	msg := parseWithConfig(t, &opts, `{"Hosts": ["a", "b"]}`, "--tag", "a", "--config", "c.json", "--tag", "b")
+	if msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	if !reflect.DeepEqual(opts.Tags, []string{"a", "b"}) {
+		t.Errorf("expected the tags [a b], got %q", opts.Tags)
+	}
+	if !reflect.DeepEqual(opts.Hosts, []string{"a", "b"}) {
+		t.Errorf("expected the hosts [a b] from the config, got %q", opts.Hosts)
+	}
+}
+
+func TestParseContextSources(t *testing.T) {
+	type options struct {
+		Workers string `cortana:"--workers, -w, , workers"`
+	}
+	// block returns once the ctx is done like a remote fetch
+	block := func(ctx stdctx.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name  string
+		setup func(c *Cortana)
+		phase string
+	}{
+		{"config", func(c *Cortana) {
+			c.AddConfig(cfg, UnmarshalContextFunc(func(ctx stdctx.Context, data []byte, v interface{}) error {
+				return block(ctx)
+			}))
+		}, "reading the config " + cfg},
+		{"env", func(c *Cortana) {
+			c.AddEnvUnmarshaler(EnvUnmarshalContextFunc(func(ctx stdctx.Context, v interface{}) error {
+				return block(ctx)
+			}))
+		}, "unmarshaling the envs"},
+		{"default provider", func(c *Cortana) {
+			c.DefaultProviderContext("--workers", func(ctx stdctx.Context) (string, error) {
+				return "", block(ctx)
+			})
+		}, "computing the default value of --workers"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr := bytes.NewBuffer(nil)
+			c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+			tc.setup(c)
+			ctx, cancel := stdctx.WithTimeout(stdctx.Background(), 10*time.Millisecond)
+			defer cancel()
+			var opts options
+			c.ParseContext(ctx, &opts, WithArgs([]string{}))
+			want := "cortana: parse interrupted while " + tc.phase + ": context deadline exceeded"
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("expected %q, got %q", want, stderr.String())
+			}
+		})
+	}
+
+	// the plain Parse passes context.Background()
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	var got stdctx.Context
+	c.DefaultProviderContext("--workers", func(ctx stdctx.Context) (string, error) {
+		got = ctx
+		return "4", nil
+	})
+	var opts options
+	c.Parse(&opts, WithArgs([]string{}))
+	if got != stdctx.Background() || opts.Workers != "4" {
+		t.Errorf("expected the background context and 4 workers, got %v and %q", got, opts.Workers)
+	}
+}
+
+// tagSet is a Value collecting the unique tags in order
+type tagSet []string
+
+func (s *tagSet) Set(v string) error {
+	for _, tag := range strings.Split(v, "+") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		found := false
+		for _, t := range *s {
+			found = found || t == tag
+		}
+		if !found {
+			*s = append(*s, tag)
+		}
+	}
+	return nil
+}
+
+func (s *tagSet) String() string {
+	return strings.Join(*s, "+")
+}
+
+func TestValueDefaultText(t *testing.T) {
+	var opts struct {
+		Tags tagSet `cortana:"--tags, -t, Web + API+web, tags"`
+	}
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	c.Parse(&opts, WithArgs([]string{"-t", "db"}))
+	// the default is rendered by String rather than as it is typed in the tag
+	if usage := c.UsageString(); !strings.Contains(usage, "(default=web+api)") {
+		t.Errorf("expected the canonical default in the usage %q", usage)
+	}
+	if opts.Tags.String() != "web+api+db" {
+		t.Errorf("expected the tags web+api+db, got %q", opts.Tags.String())
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	type options struct {
+		MaxBody ByteSize `cortana:"--max-body, -, 4MB, max body size"`
+	}
+	cases := []struct {
+		args []string
+		want ByteSize
+	}{
+		{nil, 4e6},
+		{[]string{"--max-body", "1.5KiB"}, 1536},
+		{[]string{"--max-body", "10mb"}, 1e7},
+		{[]string{"--max-body", "512"}, 512},
+		{[]string{"--max-body", "0"}, 0},
+	}
+	for _, c := range cases {
+		var opts options
+		if msg := parseArgs(t, &opts, c.args...); msg != "" {
+			t.Errorf("%q: unexpected error %q", c.args, msg)
+			continue
+		}
+		if opts.MaxBody != c.want {
+			t.Errorf("%q: expected %d, got %d", c.args, c.want, opts.MaxBody)
+		}
+	}
+
+	errs := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--max-body", "-4MiB"}, `invalid value "-4MiB" for --max-body: size "-4MiB" is negative`},
+		{[]string{"--max-body=-1"}, `invalid value "-1" for --max-body: size "-1" is negative`},
+		{[]string{"--max-body", "4XB"}, `invalid value "4XB" for --max-body: unknown unit "XB"`},
+	}
+	for _, e := range errs {
+		var opts options
+		if msg := parseArgs(t, &opts, e.args...); !strings.Contains(msg, e.want) {
+			t.Errorf("%q: expected the error %q, got %q", e.args, e.want, msg)
+		}
+	}
+}
+
+func TestFlagSpellingCollisions(t *testing.T) {
+	cases := []struct {
+		name string
+		v    interface{}
+		opts []Option
+	}{
+		{"alias and long", &struct {
+			A bool `cortana:"--color|--colour, -, false, a"`
+			B bool `cortana:"--colour, -, false, b"`
+		}{}, nil},
+		{"alias and alias", &struct {
+			A bool `cortana:"--color|--tint, -, false, a"`
+			B bool `cortana:"--shade|--tint, -, false, b"`
+		}{}, nil},
+		{"deprecated and long", &struct {
+			A bool `cortana:"--color, -, false, a" deprecated:"--paint"`
+			B bool `cortana:"--paint, -, false, b"`
+		}{}, nil},
+		{"deprecated and alias", &struct {
+			A bool `cortana:"--color, -, false, a" deprecated:"--paint"`
+			B bool `cortana:"--shade|--paint, -, false, b"`
+		}{}, nil},
+		{"negation and long", &struct {
+			A bool `cortana:"--cache, -, false, a"`
+			B bool `cortana:"--no-cache, -, false, b"`
+		}{}, nil},
+		{"negation and alias", &struct {
+			A bool `cortana:"--cache, -, false, a"`
+			B bool `cortana:"--fresh|--no-cache, -, false, b"`
+		}{}, nil},
+		{"negation and deprecated", &struct {
+			A bool `cortana:"--cache, -, false, a"`
+			B bool `cortana:"--fresh, -, false, b" deprecated:"--no-cache"`
+		}{}, nil},
+		{"alias and predefined", &struct {
+			A bool `cortana:"--settings|--config, -, false, a"`
+		}{}, []Option{StrictTags(), ConfFlag("--config", "-c", nil)}},
+		{"negation folded", &struct {
+			A bool `cortana:"--Cache, -, false, a"`
+			B bool `cortana:"--no-cache, -, false, b"`
+		}{}, []Option{CaseInsensitiveFlags()}},
+		{"alias folded", &struct {
+			A bool `cortana:"--color|--Tint, -, false, a"`
+			B bool `cortana:"--tint, -, false, b"`
+		}{}, []Option{CaseInsensitiveFlags()}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr := bytes.NewBuffer(nil)
+			c := New(append([]Option{ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr)}, tc.opts...)...)
+			c.Parse(tc.v, WithArgs([]string{}))
+			if !strings.Contains(stderr.String(), "cortana: flag") {
+				t.Errorf("expected a collision, got %q", stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package cortana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the status of a doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// doctorCheck is the result of a check of the doctor command
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// DoctorCommand adds the command "doctor" which diagnoses the configuration of the
+// tool: the config files, the envs with the prefix like "MYAPP_", the completion of
+// the shell in $SHELL, the rc file, the aliases and the flags of the commands added
+// WithFlags. It fails if any check fails
+func DoctorCommand(envPrefix string) Option {
+	return func(c *Cortana) {
+		c.AddCommand("doctor", func() {
+			c.doctor(envPrefix)
+		}, "diagnose the configuration")
+	}
+}
+
+func (c *Cortana) doctor(envPrefix string) {
+	format := "text"
+	if c.predefined.output.long != "" || c.predefined.output.short != "" {
+		c.Parse(&struct{}{})
+		if c.output != "" && c.output != outputFormats[0] {
+			format = c.output
+		}
+	} else {
+		opts := struct {
+			Output string `cortana:"--output, -o, text, output format, text or json"`
+		}{}
+		c.Parse(&opts)
+		format = opts.Output
+	}
+
+	var checks []doctorCheck
+	checks = append(checks, c.checkConfigs()...)
+	checks = append(checks, c.checkEnvs(envPrefix)...)
+	checks = append(checks, c.checkCompletion()...)
+	checks = append(checks, c.checkRCFile()...)
+	checks = append(checks, c.checkAliases()...)
+	checks = append(checks, c.checkCommandFlags()...)
+
+	switch format {
+	case "text":
+		for _, check := range checks {
+			fmt.Fprintf(c.stdout, "[%s] %s: %s\n", check.Status, check.Name, check.Message)
+			if check.Hint != "" && check.Status != checkPass {
+				fmt.Fprintf(c.stdout, "       %s\n", check.Hint)
+			}
+		}
+	case "json":
+		data, err := json.MarshalIndent(checks, "", "  ")
+		if err != nil {
+			c.fatal(err)
+			return
+		}
+		fmt.Fprintln(c.stdout, string(data))
+	default:
+		if format == "yaml" {
+			c.Print(checks)
+			break
+		}
+		c.fatal(errors.New("unknown format: " + format + ", should be text or json"))
+		return
+	}
+
+	var failed int
+	for _, check := range checks {
+		if check.Status == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		c.fatal(fmt.Errorf("doctor: %d of %d checks failed", failed, len(checks)))
+	}
+}
+
+// checkConfigs checks if the config files exist and parse
+func (c *Cortana) checkConfigs() []doctorCheck {
+	var checks []doctorCheck
+	for _, cfg := range c.configs {
+		if cfg.path == "" || cfg.unmarshaler == nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(cfg.path)
+		if os.IsNotExist(err) {
+			checks = append(checks, doctorCheck{Name: "config", Status: checkWarn,
+				Message: cfg.path + " is not found", Hint: "create it if the defaults are not what you want"})
+			continue
+		}
+		if err == nil {
+			m := make(map[string]interface{})
+			err = cfg.unmarshaler.Unmarshal(data, &m)
+		}
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: "config", Status: checkFail,
+				Message: cfg.path + ": " + err.Error(), Hint: "fix or remove the file"})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: "config", Status: checkPass, Message: cfg.path + " parses"})
+	}
+	return checks
+}
+
+// checkEnvs checks if the envs with the prefix match the flags of the commands, an
+// env like MYAPP_LOG_LEVEL matches --log-level
+func (c *Cortana) checkEnvs(prefix string) []doctorCheck {
+	if prefix == "" {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, cmd := range c.commands.scan("") {
+		if cmd.options == nil {
+			continue
+		}
+		flags, _ := parseCortanaTags(reflect.New(cmd.options), c.tags)
+		for _, f := range flags {
+			known[strings.TrimLeft(f.long, "-")] = true
+			if f.env != "" {
+				known[f.env] = true
+			}
+		}
+	}
+
+	var envs []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			envs = append(envs, strings.SplitN(kv, "=", 2)[0])
+		}
+	}
+	sort.Strings(envs)
+	var checks []doctorCheck
+	for _, env := range envs {
+		name := strings.ToLower(strings.Replace(strings.TrimPrefix(env, prefix), "_", "-", -1))
+		if known[env] {
+			checks = append(checks, doctorCheck{Name: "env", Status: checkPass, Message: env + " is bound by an env tag"})
+			continue
+		}
+		if known[name] {
+			checks = append(checks, doctorCheck{Name: "env", Status: checkPass, Message: env + " is set for --" + name})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: "env", Status: checkWarn, Message: env + " is set but matches no flag",
+			Hint: "unset it if it is stale: unset " + env})
+	}
+	return checks
+}
+
+// completionPaths returns where the completion of the tool is installed for the
+// shell, the first one is where the user installs it
+func completionPaths(shell, name string) []string {
+	home, _ := os.UserHomeDir()
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	switch shell {
+	case "bash":
+		return []string{
+			filepath.Join(dataHome, "bash-completion", "completions", name),
+			filepath.Join("/usr/local/share/bash-completion/completions", name),
+			filepath.Join("/usr/share/bash-completion/completions", name),
+			filepath.Join("/etc/bash_completion.d", name),
+		}
+	case "zsh":
+		return []string{
+			filepath.Join(home, ".zsh", "completions", "_"+name),
+			filepath.Join("/usr/local/share/zsh/site-functions", "_"+name),
+			filepath.Join("/usr/share/zsh/site-functions", "_"+name),
+		}
+	case "fish":
+		return []string{
+			filepath.Join(configHome, "fish", "completions", name+".fish"),
+			filepath.Join("/usr/share/fish/vendor_completions.d", name+".fish"),
+		}
+	}
+	return nil
+}
+
+// checkCompletion checks if the completion of the tool is installed for the shell
+// in $SHELL
+func (c *Cortana) checkCompletion() []doctorCheck {
+	var shell string
+	if env := os.Getenv("SHELL"); env != "" {
+		shell = filepath.Base(env)
+	}
+	name := filepath.Base(c.ctx.name)
+	paths := completionPaths(shell, name)
+	if len(paths) == 0 {
+		return []doctorCheck{{Name: "completion", Status: checkWarn, Message: "the shell " + strconv.Quote(shell) + " is not supported",
+			Hint: "set $SHELL to bash, zsh or fish to check the completion"}}
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return []doctorCheck{{Name: "completion", Status: checkPass, Message: "installed for " + shell + " at " + path}}
+		}
+	}
+	return []doctorCheck{{Name: "completion", Status: checkWarn, Message: "not installed for " + shell,
+		Hint: "install the completion script of " + name + " to " + paths[0]}}
+}
+
+// checkRCFile checks if the rc file loads
+func (c *Cortana) checkRCFile() []doctorCheck {
+	if c.rcfile == "" {
+		return nil
+	}
+	if _, err := loadRCFile(c.rcfile); err != nil {
+		return []doctorCheck{{Name: "rcfile", Status: checkFail, Message: err.Error(), Hint: "fix or remove the file"}}
+	}
+	return []doctorCheck{{Name: "rcfile", Status: checkPass, Message: c.rcfile + " loads"}}
+}
+
+// checkAliases checks if the aliases lead to the commands
+func (c *Cortana) checkAliases() []doctorCheck {
+	var checks []doctorCheck
+	for _, cmd := range c.commands.scan("") {
+		if !cmd.Alias {
+			continue
+		}
+		args, err := splitArgs(cmd.definition)
+		if err == nil && len(args) > 0 {
+			if target, _ := c.searchCommand(args); target == nil || target.Alias {
+				err = errors.New("it leads to no command")
+			}
+		}
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: "alias", Status: checkFail,
+				Message: fmt.Sprintf("%s = %s: %v", cmd.Path, cmd.definition, err), Hint: "fix the definition of the alias"})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: "alias", Status: checkPass, Message: cmd.Path + " = " + cmd.definition})
+	}
+	return checks
+}
+
+// checkCommandFlags checks the tags of the commands added WithFlags, like the
+// duplicated flags and the invalid default values
+func (c *Cortana) checkCommandFlags() []doctorCheck {
+	var checks []doctorCheck
+	for _, cmd := range c.commands.scan("") {
+		if cmd.options == nil {
+			continue
+		}
+		if _, _, err := inspect(reflect.New(cmd.options).Interface(), c.tags); err != nil {
+			checks = append(checks, doctorCheck{Name: "flags", Status: checkFail,
+				Message: cmd.Path + ": " + err.Error(), Hint: "fix the tags of the options"})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: "flags", Status: checkPass, Message: cmd.Path + " has valid flags"})
+	}
+	return checks
+}
@@ -0,0 +1,51 @@
+package cortana
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorCompletion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	installed := filepath.Join(home, ".config", "fish", "completions", "mytool.fish")
+	if err := os.MkdirAll(filepath.Dir(installed), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(installed, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		shell   string
+		status  string
+		message string
+	}{
+		{"/usr/bin/fish", checkPass, "installed for fish at " + installed},
+		{"/bin/zsh", checkWarn, "not installed for zsh"},
+		{"", checkWarn, `the shell "" is not supported`},
+		{"/bin/tcsh", checkWarn, `the shell "tcsh" is not supported`},
+	}
+	c := New()
+	c.ctx.name = "/usr/local/bin/mytool"
+	for _, tc := range cases {
+		t.Setenv("SHELL", tc.shell)
+		checks := c.checkCompletion()
+		if len(checks) != 1 || checks[0].Status != tc.status || checks[0].Message != tc.message {
+			t.Errorf("%q: expected [%s] %s, got %+v", tc.shell, tc.status, tc.message, checks)
+		}
+		if tc.status != checkPass && checks[0].Hint == "" {
+			t.Errorf("%q: expected a hint", tc.shell)
+		}
+	}
+
+	t.Setenv("SHELL", "/bin/zsh")
+	if hint := c.checkCompletion()[0].Hint; !strings.HasSuffix(hint, filepath.Join(home, ".zsh", "completions", "_mytool")) {
+		t.Errorf("expected the hint to name the install path, got %q", hint)
+	}
+}
@@ -0,0 +1,99 @@
+package cortana
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// parseDuration parses a duration like time.ParseDuration, the units "d" (24h) and
+// "w" (7d) are also accepted if extended is true
+func parseDuration(s string, extended bool) (time.Duration, error) {
+	if !extended {
+		return time.ParseDuration(s)
+	}
+
+	orig := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "0" {
+		return 0, nil
+	}
+	if s == "" {
+		return 0, errors.New("invalid duration " + strconv.Quote(orig))
+	}
+
+	var d time.Duration
+	for s != "" {
+		// the number part
+		i := 0
+		for i < len(s) && (s[i] == '.' || s[i] >= '0' && s[i] <= '9') {
+			i++
+		}
+		if i == 0 {
+			return 0, errors.New("invalid duration " + strconv.Quote(orig))
+		}
+		number := s[:i]
+		s = s[i:]
+
+		// the unit part
+		i = 0
+		for i < len(s) && s[i] != '.' && (s[i] < '0' || s[i] > '9') {
+			i++
+		}
+		unit := s[:i]
+		s = s[i:]
+
+		var part time.Duration
+		switch unit {
+		case "d", "w":
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, errors.New("invalid duration " + strconv.Quote(orig))
+			}
+			day := float64(24 * time.Hour)
+			if unit == "w" {
+				day *= 7
+			}
+			part = time.Duration(n * day)
+		case "y", "mo", "M":
+			return 0, errors.New("invalid duration " + strconv.Quote(orig) +
+				": months and years are ambiguous, use days (d) or weeks (w) instead")
+		default:
+			var err error
+			if part, err = time.ParseDuration(number + unit); err != nil {
+				return 0, errors.New("invalid duration " + strconv.Quote(orig) +
+					": the valid units are w, d, h, m, s, ms, us and ns")
+			}
+		}
+		d += part
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// parseTime parses the time with the layout, RFC3339 by default, "now" is the
+// current time
+func parseTime(s, layout string) (time.Time, error) {
+	if s == "now" {
+		return time.Now(), nil
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	return time.Parse(layout, s)
+}
+
+// durationHint hints the accepted units of an extended duration flag in the usage
+func durationHint(f *flag) string {
+	if f.extendedDuration && f.rv.Type() == reflect.TypeOf(time.Duration(0)) {
+		return " (w|d|h|m|s)"
+	}
+	return ""
+}
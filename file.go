@@ -0,0 +1,71 @@
+package cortana
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// File is the path of a file given by a flag, the path is expanded like the config
+// files and checked when parsing. The tag mode:"r" (the default) requires the file to
+// exist and mode:"w" requires its directory to exist. The path "-" means stdin, or
+// stdout with mode:"w"
+type File struct {
+	Path string
+
+	write bool // the file is opened for writing
+}
+
+// Open opens the file for reading, or creates it for writing with mode:"w". It
+// returns os.Stdin or os.Stdout for "-"
+func (f File) Open() (*os.File, error) {
+	if f.Path == "-" && f.write {
+		return os.Stdout, nil
+	}
+	if f.Path == "-" {
+		return os.Stdin, nil
+	}
+	if f.write {
+		return os.Create(f.Path)
+	}
+	return os.Open(f.Path)
+}
+
+// String returns the path
+func (f File) String() string {
+	return f.Path
+}
+
+var fileType = reflect.TypeOf(File{})
+
+// parseFile expands the path and checks the file by the mode
+func parseFile(s string, mode string) (File, error) {
+	if s == "-" {
+		return File{Path: s, write: mode == "w"}, nil
+	}
+	path, err := normalizePath(s)
+	if err != nil {
+		return File{}, err
+	}
+	if mode == "w" {
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(dir); err != nil {
+			return File{}, &os.PathError{Op: "open", Path: path, Err: unwrapPathError(err)}
+		}
+		return File{Path: path, write: true}, nil
+	}
+	fd, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	fd.Close()
+	return File{Path: path}, nil
+}
+
+// unwrapPathError returns the cause of the error of the path
+func unwrapPathError(err error) error {
+	if e, ok := err.(*os.PathError); ok {
+		return e.Err
+	}
+	return err
+}
@@ -1,26 +1,90 @@
 package cortana
 
 import (
+	stdctx "context"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 type flag struct {
 	name         string // the field name
+	path         string // the field path from the root struct, like Server.Port
 	long         string
 	short        string
 	required     bool
 	defaultValue string
 	description  string
 	rv           reflect.Value
+
+	configKey string // the dotted path of the value in the config file
+	group     string // the section of the flag in the usage
+	source    Source // where the value comes from
+
+	extendedDuration bool   // accept the units "d" and "w" for a duration
+	optArg           string // the implied value if the argument is omitted
+	hasOptArg        bool   // the argument is optional and only accepted with '='
+
+	defaultFunc func(stdctx.Context) (string, error) // computes the default value if no source supplies it
+	transforms  []string                             // the names of the transforms applied to the value
+	join        string                               // the separator joining the remaining positional args
+	hasJoin     bool                                 // the nonflag captures all the remaining positional args
+
+	requiredIf     []string       // required if any of the conditions like --tls or --tls=true holds
+	requiredUnless []string       // required unless any of the flags is set
+	allowDup       []string       // "long" or "short", the name wins over the same one of another flag
+	layout         string         // the layout of a time, time.RFC3339 by default
+	schemes        []string       // the accepted schemes of a url, any scheme if empty
+	count          bool           // the integer counts the occurrences instead of taking a value
+	negatable      bool           // the bool flag is negated by --no-<flag>
+	sep            string         // the separator splitting a value of the slice into elements
+	choices        []string       // the allowed values, any value if empty
+	pattern        string         // the regular expression the whole value must match
+	re             *regexp.Regexp // the compiled pattern
+	min, max       string         // the bounds of a number, like 1 or 1s for a duration
+	minValue       reflect.Value  // the parsed lower bound, invalid if there is none
+	maxValue       reflect.Value  // the parsed upper bound, invalid if there is none
+	hidden         bool           // the flag is parsed but not shown in the usage
+	secret         bool           // the value is masked wherever it is reported
+	deprecated     []string       // the old names of the flag, which are warned if used
+	excludes       []string       // the flags which can not be given with the flag
+	env            string         // the name of the env variable which sets the flag
+	mode           string         // "r" or "w" for a File, the file is read by default
+	repeat         string         // the policy of the repeated flag, overrides the ParseOption
+	occurrences    int            // the times the flag is given in the args
+	rest           bool           // the nonflag takes all the remaining args verbatim
+	aliases        []string       // the other long names of the flag
+	filled         int            // the number of the elements of an array filled so far
+	format         string         // "json" if the value is a json literal decoded into the field
+	encoding       string         // "base64" or "hex" for a []byte, the raw string by default
+	placeholder    string         // the name of the value in the usage like FILE, derived from the name by default
+	candidates     []string       // the values offered by the completion, the choices by default
+	permOnly       bool           // the file mode has only the permission bits
+
+	complete func(prefix string) []string // lists the candidate values for the completion
 }
 
 // nonflag is in fact a flag without prefix "-"
 type nonflag flag
 
-func parseFlag(tag string, name string, rv reflect.Value) *flag {
+func parseFlag(tag string, name string, rv reflect.Value) (*flag, error) {
 	f := &flag{name: name, rv: rv}
-	parts := strings.Split(tag, ",")
+	if namedTag.MatchString(tag) {
+		if err := f.parseNamedTag(tag); err != nil {
+			return nil, fmt.Errorf("invalid tag %q: %v", tag, err)
+		}
+		return f, nil
+	}
+	fields := tagFields(tag)
 
 	const (
 		long = iota
@@ -29,17 +93,20 @@ func parseFlag(tag string, name string, rv reflect.Value) *flag {
 		description
 	)
 	state := long
-	for i := 0; i < len(parts); i++ {
-		p := strings.TrimSpace(parts[i])
+	for i := 0; i < len(fields); i++ {
+		p := fields[i].value
 		switch state {
 		case long:
-			f.long = p
+			// the long names like --color|--colour, the first one is canonical
+			names := strings.Split(p, "|")
+			f.long, f.aliases = names[0], names[1:]
 			state = short
 		case short:
 			f.short = p
 			state = defaultValue
 		case defaultValue:
-			if p == "-" {
+			// a quoted "-" is a default value rather than the required mark
+			if p == "-" && !fields[i].quoted {
 				f.required = true
 			} else {
 				// set to empty value
@@ -50,9 +117,460 @@ func parseFlag(tag string, name string, rv reflect.Value) *flag {
 			}
 			state = description
 		case description:
-			f.description = strings.TrimSpace(strings.Join(parts[i:], ","))
-			return f
+			// the description takes the rest of the tag, the commas need no escaping
+			f.description = strings.ReplaceAll(strings.TrimSpace(tag[fields[i].offset:]), `\,`, ",")
+			return f, nil
+		}
+	}
+	return f, nil
+}
+
+// namedTag matches the tag in the named syntax, like "long=--name short=-n"
+var namedTag = regexp.MustCompile(`^\s*[a-z]+=`)
+
+// parseNamedTag parses the tag in the named syntax, the keys are in any order and
+// the values with spaces are quoted, like desc='who to greet'
+func (f *flag) parseNamedTag(tag string) error {
+	f.long, f.short = "-", "-"
+	seen := make(map[string]bool)
+	for s := strings.TrimSpace(tag); s != ""; s = strings.TrimLeft(s, " ") {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.Contains(s[:eq], " ") {
+			return fmt.Errorf("missing value of %q", strings.Fields(s)[0])
+		}
+		key, value := s[:eq], ""
+		if s = s[eq+1:]; s != "" && (s[0] == '\'' || s[0] == '"') {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return fmt.Errorf("unterminated quote in the value of %s", key)
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicated key %s", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "long":
+			names := strings.Split(value, "|")
+			f.long, f.aliases = names[0], names[1:]
+		case "short":
+			f.short = value
+		case "default":
+			f.defaultValue = value
+		case "desc":
+			f.description = value
+		case "required":
+			required, err := parseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid required: %v", err)
+			}
+			f.required = required
+		default:
+			return fmt.Errorf("unknown key %s, should be one of long, short, default, desc or required", key)
 		}
 	}
-	return f
+	return nil
+}
+
+// tagField is a comma separated field of the cortana tag
+type tagField struct {
+	value  string
+	quoted bool // the field is quoted like "a,b", so it is taken literally
+	offset int  // where the field starts in the tag
+}
+
+// tagFields splits the cortana tag by the commas and trims the spaces, a comma
+// escaped like a\,b or in a quoted field like "a,b" is kept in the field
+func tagFields(tag string) []tagField {
+	var fields []tagField
+	for i := 0; i <= len(tag); i++ {
+		field := tagField{offset: i}
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		var value strings.Builder
+		if strings.HasPrefix(tag[i:], `"`) && strings.Count(tag[i:], `"`) > 1 {
+			end := i + 1 + strings.IndexByte(tag[i+1:], '"')
+			field.value, field.quoted = tag[i+1:end], true
+			// the text after the closing quote is ignored
+			i = len(tag)
+			if comma := strings.IndexByte(tag[end:], ','); comma >= 0 {
+				i = end + comma
+			}
+			fields = append(fields, field)
+			continue
+		}
+		for ; i < len(tag) && tag[i] != ','; i++ {
+			if tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',' {
+				i++
+			}
+			value.WriteByte(tag[i])
+		}
+		field.value = strings.TrimSpace(value.String())
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// isSet reports whether the value is set by the config, env or args
+func (f *flag) isSet() bool {
+	return f.source.Kind != "" && f.source.Kind != SourceDefault
+}
+
+// persistent marks the flag as a persistent one, which is listed in the usage under
+// "Global options" unless it is grouped by itself
+func (f *flag) persistent() {
+	if f.group == "" {
+		f.group = "Global"
+	}
+}
+
+// dropName drops the long or short name which is taken by another flag
+func (f *flag) dropName(kind string) {
+	if kind == "short" {
+		f.short = "-"
+	} else {
+		f.long = "-"
+	}
+}
+
+// kind returns the kind of the value, a pointer like *int is seen through
+func (f *flag) kind() reflect.Kind {
+	if !f.rv.IsValid() {
+		return reflect.Invalid
+	}
+	rt := f.rv.Type()
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt.Kind()
+}
+
+// takesValue reports if the flag takes a value, a bool or counting flag does not
+func (f *flag) takesValue() bool {
+	return f.kind() != reflect.Bool && !f.count
+}
+
+// accumulates reports if the flag accumulates the repeated occurrences, like the
+// lists, maps, counting flags and Values
+func (f *flag) accumulates() bool {
+	if f.count || isList(f.rv) || f.kind() == reflect.Map {
+		return true
+	}
+	return f.rv.CanAddr() && f.rv.Addr().Type().Implements(valueType)
+}
+
+// negation returns the negated form of the bool flag like --no-cache for --cache,
+// it is empty if the flag can not be negated
+func (f *flag) negation() string {
+	if !f.negatable || !strings.HasPrefix(f.long, "--") {
+		return ""
+	}
+	return "--no-" + f.long[2:]
+}
+
+// increment increments the counting flag by one occurrence
+func (f *flag) increment() error {
+	rv := f.rv
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rv.SetInt(rv.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rv.SetUint(rv.Uint() + 1)
+	default:
+		return fmt.Errorf("%s counts the occurrences but %s is not an integer", f.displayName(), f.path)
+	}
+	return nil
+}
+
+// displayName returns the name of the flag used in messages
+func (f *flag) displayName() string {
+	if f.long != "-" && f.long != "" {
+		return f.long
+	}
+	return f.short
+}
+
+// Value is the interface of the custom types of the flags, Set is called with the
+// default value and every occurrence of the flag in order, so the type decides how
+// the values accumulate. String renders the default value in the usage
+type Value interface {
+	Set(s string) error
+	String() string
+}
+
+// IsSetter is optionally implemented by a Value to tell if it is set, which is
+// checked instead of the zero value for a required flag
+type IsSetter interface {
+	IsSet() bool
+}
+
+var (
+	valueType           = reflect.TypeOf((*Value)(nil)).Elem()
+	isSetterType        = reflect.TypeOf((*IsSetter)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	fileModeType        = reflect.TypeOf(os.FileMode(0))
+	ipType              = reflect.TypeOf(net.IP{})
+	ipNetType           = reflect.TypeOf(net.IPNet{})
+	urlType             = reflect.TypeOf(url.URL{})
+)
+
+// isText reports if the type is parsed from the text as a whole, like a Value or
+// net.IP which parse the text themselves and net.IPNet and url.URL which cortana parses
+func isText(rt reflect.Type) bool {
+	return rt == ipNetType || rt == urlType || rt == fileType || isBytes(rt) || reflect.PtrTo(rt).Implements(valueType) ||
+		reflect.PtrTo(rt).Implements(textUnmarshalerType)
+}
+
+// isBytes reports if the type is a []byte which is decoded from the text as a whole,
+// the types parsing the text themselves like net.IP are excluded
+func isBytes(rt reflect.Type) bool {
+	return rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8 &&
+		!reflect.PtrTo(rt).Implements(valueType) && !reflect.PtrTo(rt).Implements(textUnmarshalerType)
+}
+
+// decodeBytes decodes the text of a []byte by the encoding, the text is taken as is
+// if there is no encoding
+func decodeBytes(s string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.DecodeString(s)
+	case "hex":
+		return hex.DecodeString(s)
+	}
+	return []byte(s), nil
+}
+
+// encodeBytes encodes the []byte by the encoding, it is the reverse of decodeBytes
+func encodeBytes(b []byte, encoding string) string {
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b)
+	case "hex":
+		return hex.EncodeToString(b)
+	}
+	return string(b)
+}
+
+// isUnset reports if the value is not set, a Value implementing IsSetter tells it
+// by itself, otherwise the zero value or an empty []byte is unset
+func isUnset(rv reflect.Value) bool {
+	if rv.CanAddr() && rv.Addr().Type().Implements(isSetterType) {
+		return !rv.Addr().Interface().(IsSetter).IsSet()
+	}
+	if isBytes(rv.Type()) {
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// typePlaceholder returns the placeholder of the value in the usage by its type,
+// like <ip>, it is empty if the type has no specific one
+func typePlaceholder(rt reflect.Type) string {
+	for rt.Kind() == reflect.Ptr || (rt.Kind() == reflect.Slice && !isText(rt)) {
+		rt = rt.Elem()
+	}
+	switch rt {
+	case ipType:
+		return "<ip>"
+	case ipNetType:
+		return "<cidr>"
+	case urlType:
+		return "<url>"
+	case fileType:
+		return "<file>"
+	}
+	return ""
+}
+
+// isList reports if the value takes the repeated flags or the remaining args, a
+// slice type which parses the text itself, like net.IP, is a single value. An array
+// is a list of a fixed length
+func isList(rv reflect.Value) bool {
+	return (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && !isText(rv.Type())
+}
+
+// resetList empties the list before the values of another source are applied, an
+// array is zeroed and filled from the first element again
+func (f *flag) resetList() {
+	if f.rv.Kind() == reflect.Array {
+		f.rv.Set(reflect.Zero(f.rv.Type()))
+		f.filled = 0
+		return
+	}
+	f.rv.Set(reflect.MakeSlice(f.rv.Type(), 0, 0))
+}
+
+// isValueOf reports if v is the value of the flag itself rather than a scratch one,
+// like the default value being checked
+func (f *flag) isValueOf(v reflect.Value) bool {
+	return v.CanAddr() && f.rv.CanAddr() && v.Type() == f.rv.Type() && v.Addr().Pointer() == f.rv.Addr().Pointer()
+}
+
+// visibleFlags returns the flags which are not hidden
+func visibleFlags(flags []*flag) []*flag {
+	visible := make([]*flag, 0, len(flags))
+	for _, f := range flags {
+		if !f.hidden {
+			visible = append(visible, f)
+		}
+	}
+	return visible
+}
+
+// requirementHint describes the conditional requirement in the usage
+func requirementHint(requiredIf, requiredUnless []string) string {
+	var hints []string
+	if len(requiredIf) > 0 {
+		hints = append(hints, "required if "+strings.Join(requiredIf, " or "))
+	}
+	if len(requiredUnless) > 0 {
+		hints = append(hints, "required unless "+strings.Join(requiredUnless, " or ")+" is given")
+	}
+	if len(hints) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(hints, ", ") + ")"
+}
+
+// splitList splits a comma separated list and trims the spaces
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// methodProvider returns a provider calling the method of the struct, the method
+// should be like "func() string", "func() (string, error)" or
+// "func(context.Context) (string, error)"
+func methodProvider(rv reflect.Value, name string) func(stdctx.Context) (string, error) {
+	return func(ctx stdctx.Context) (string, error) {
+		m := reflect.Value{}
+		if rv.CanAddr() {
+			m = rv.Addr().MethodByName(name)
+		}
+		if !m.IsValid() {
+			m = rv.MethodByName(name)
+		}
+		if !m.IsValid() {
+			return "", fmt.Errorf("method %s of %s not found", name, rv.Type())
+		}
+		switch fn := m.Interface().(type) {
+		case func() string:
+			return fn(), nil
+		case func() (string, error):
+			return fn()
+		case func(stdctx.Context) (string, error):
+			return fn(ctx)
+		}
+		return "", fmt.Errorf("method %s of %s should be func() string, func() (string, error) or "+
+			"func(context.Context) (string, error)", name, rv.Type())
+	}
+}
+
+// parseURL parses the url and checks its scheme if the schemes are given
+func parseURL(s string, schemes []string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.Unwrap(err)
+	}
+	if len(schemes) == 0 {
+		return u, nil
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return u, nil
+		}
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("missing scheme, should be one of %s", strings.Join(schemes, ", "))
+	}
+	return nil, fmt.Errorf("scheme %q is not allowed, should be one of %s", u.Scheme, strings.Join(schemes, ", "))
+}
+
+// splitEscaped splits the string by the separator, a separator escaped by '\' is
+// kept in the element, like a\,b
+func splitEscaped(s string, sep string) []string {
+	var elems []string
+	var elem strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && strings.HasPrefix(s[i+1:], sep) {
+			elem.WriteString(sep)
+			i += len(sep)
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			elems = append(elems, elem.String())
+			elem.Reset()
+			i += len(sep) - 1
+			continue
+		}
+		elem.WriteByte(s[i])
+	}
+	return append(elems, elem.String())
+}
+
+// intBase returns the base to parse the integer, the prefixes 0x, 0o and 0b are
+// honored like Go, but a plain leading zero like 007 is still decimal rather than octal
+func intBase(s string) int {
+	s = strings.TrimLeft(s, "+-")
+	if len(s) > 1 && s[0] == '0' && strings.ContainsRune("xXoObB", rune(s[1])) {
+		return 0
+	}
+	return 10
+}
+
+// isFileMode reports if the value is a file mode, which is an os.FileMode or an
+// unsigned integer with format:"filemode"
+func (f *flag) isFileMode(rt reflect.Type) bool {
+	return rt == fileModeType || (f.format == "filemode" && rt.Kind() >= reflect.Uint && rt.Kind() <= reflect.Uint64)
+}
+
+// parseFileMode parses the file mode in octal, with or without a prefix like 0644,
+// 644 or 0o644
+func parseFileMode(s string, permOnly bool) (uint64, error) {
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0o"), "0O")
+	mode, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil {
+		return 0, errors.New("should be octal like 0644")
+	}
+	if permOnly && mode&^uint64(os.ModePerm) != 0 {
+		return 0, errors.New("should be only the permission bits like 0644")
+	}
+	return mode, nil
+}
+
+// parseBool parses a bool like strconv.ParseBool, yes/no and on/off are also
+// accepted case-insensitively
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid bool %q, should be one of true, false, yes, no, on, off, 1 or 0", s)
+	}
+	return b, nil
 }
@@ -0,0 +1,66 @@
+package cortana
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFlagTokenizer(t *testing.T) {
+	var s string
+	cases := []struct {
+		tag  string
+		want flag
+	}{
+		{`--sep, -s, a\,b, the separator`,
+			flag{long: "--sep", short: "-s", defaultValue: "a,b", description: "the separator"}},
+		{`--sep, -s, "a,b", the separator`,
+			flag{long: "--sep", short: "-s", defaultValue: "a,b", description: "the separator"}},
+		{`--sep, -s, "a\,b", the separator`,
+			flag{long: "--sep", short: "-s", defaultValue: `a\,b`, description: "the separator"}},
+		{`--odd\,name, -\,, , odd names`,
+			flag{long: "--odd,name", short: "-,", description: "odd names"}},
+		// a quoted empty string is an empty default, a quoted "-" is not the required mark
+		{`--name, -n, "", the name`, flag{long: "--name", short: "-n", description: "the name"}},
+		{`--name, -n, '', the name`, flag{long: "--name", short: "-n", description: "the name"}},
+		{`--name, -n, "-", the name`, flag{long: "--name", short: "-n", defaultValue: "-", description: "the name"}},
+		{`--name, -n, -, the name`, flag{long: "--name", short: "-n", required: true, description: "the name"}},
+		{`"", -n, , the name`, flag{long: "", short: "-n", description: "the name"}},
+		// the description takes the rest, an escaped comma is unescaped and the other
+		// escapes are kept as they are
+		{`--name, -n, , the name, or the alias`,
+			flag{long: "--name", short: "-n", description: "the name, or the alias"}},
+		{`--name, -n, , the name\, or the alias`,
+			flag{long: "--name", short: "-n", description: "the name, or the alias"}},
+		{`--path, -p, , a path like C:\temp\new, "quoted"`,
+			flag{long: "--path", short: "-p", description: `a path like C:\temp\new, "quoted"`}},
+	}
+	for _, c := range cases {
+		f, err := parseFlag(c.tag, "field", reflect.ValueOf(&s).Elem())
+		if err != nil {
+			t.Errorf("%s: %v", c.tag, err)
+			continue
+		}
+		got := flag{long: f.long, short: f.short, defaultValue: f.defaultValue, description: f.description,
+			required: f.required}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected %+v, got %+v", c.tag, c.want, got)
+		}
+	}
+}
+
+func TestEscapedDefaultValue(t *testing.T) {
+	var opts struct {
+		Pair  []string `cortana:"--pair, -p, a\\,b, pairs"`
+		Tags  []string `cortana:"--tag, -t, a\\,b, tags" sep:","`
+		Sep   string   `cortana:"--sep, -s, \",\", the separator"`
+		Empty string   `cortana:"--empty, -e, \"\", empty by default"`
+	}
+	if msg := parseArgs(t, &opts); msg != "" {
+		t.Fatalf("unexpected error %q", msg)
+	}
+	// the escaped comma is a part of the value, it separates the elements only with sep
+	if !reflect.DeepEqual(opts.Pair, []string{"a,b"}) || !reflect.DeepEqual(opts.Tags, []string{"a", "b"}) ||
+		opts.Sep != "," || opts.Empty != "" {
+		t.Errorf("unexpected values %+v", opts)
+	}
+}
@@ -1,8 +1,13 @@
 module github.com/shafreeck/cortana
 
-go 1.14
+go 1.18
 
 require (
 	github.com/google/btree v1.0.0
 	github.com/muesli/reflow v0.3.0
 )
+
+require (
+	github.com/mattn/go-runewidth v0.0.12 // indirect
+	github.com/rivo/uniseg v0.2.0 // indirect
+)
@@ -0,0 +1,136 @@
+package cortana
+
+import "reflect"
+
+// Defaulter is implemented by the option structs which set their default values
+// in code, like runtime.NumCPU(), that can not be expressed in a tag
+type Defaulter interface {
+	SetDefaults()
+}
+
+// visitStructs calls fn with the pointers of the nested structs depth-first and
+// then the pointer of the struct itself
+func visitStructs(rv reflect.Value, fn func(v interface{})) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || !rv.CanAddr() {
+		return
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		ft := rt.Field(i)
+		fv := rv.Field(i)
+		if ft.PkgPath != "" && !ft.Anonymous {
+			continue
+		}
+		if fv.Kind() == reflect.Struct || fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			visitStructs(fv, fn)
+		}
+	}
+	if p := rv.Addr(); p.CanInterface() {
+		fn(p.Interface())
+	}
+}
+
+// setDefaults calls SetDefaults of v and its nested structs
+func setDefaults(v interface{}) {
+	visitStructs(reflect.ValueOf(v), func(v interface{}) {
+		if d, ok := v.(Defaulter); ok {
+			d.SetDefaults()
+		}
+	})
+}
+
+// Validator is implemented by the option structs which check themselves after
+// parsing, like the constraints across the fields
+type Validator interface {
+	Validate() error
+}
+
+// validateStructs calls Validate of the nested structs of v depth-first and then v
+// itself, it stops at the first error
+func validateStructs(v interface{}) error {
+	var err error
+	visitStructs(reflect.ValueOf(v), func(v interface{}) {
+		if vd, ok := v.(Validator); ok && err == nil {
+			err = vd.Validate()
+		}
+	})
+	return err
+}
+
+// OnBeforeRun registers a hook which runs before every command with its args, like
+// opening a connection. The hooks run in the order of registration and an error
+// aborts the command, which is reported as the failures of the command
+func (c *Cortana) OnBeforeRun(hook func(cmd *Command, args []string) error) {
+	c.beforeRun = append(c.beforeRun, hook)
+}
+
+// OnAfterRun registers a hook which runs after every command with its error, like
+// flushing the logs. The hooks run in the order of registration
+func (c *Cortana) OnAfterRun(hook func(cmd *Command, err error)) {
+	c.afterRun = append(c.afterRun, hook)
+}
+
+// BeforeRun registers a hook which runs before the command, inside the hooks of
+// OnBeforeRun
+func BeforeRun(hook func(cmd *Command, args []string) error) CommandOption {
+	return func(cmd *Command) {
+		cmd.beforeRun = append(cmd.beforeRun, hook)
+	}
+}
+
+// AfterRun registers a hook which runs after the command, inside the hooks of
+// OnAfterRun
+func AfterRun(hook func(cmd *Command, err error)) CommandOption {
+	return func(cmd *Command) {
+		cmd.afterRun = append(cmd.afterRun, hook)
+	}
+}
+
+// Middleware wraps the execution of the commands, it calls next to run the command
+// and may do something around it, like timing, or skip it, like a dry run gate
+type Middleware func(next func(cmd *Command)) func(cmd *Command)
+
+// UseMiddleware registers the middlewares, the first registered one is the outermost.
+// They wrap the hooks and the command
+func (c *Cortana) UseMiddleware(mws ...Middleware) {
+	c.middlewares = append(c.middlewares, mws...)
+}
+
+// execute runs the command within the middlewares and the hooks. An alias runs the
+// ones of the command it resolves to instead of its own
+func (c *Cortana) execute(cmd *Command) error {
+	if cmd.Alias {
+		return cmd.exec()
+	}
+	var err error
+	run := func(cmd *Command) {
+		err = c.runHooked(cmd)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		run = c.middlewares[i](run)
+	}
+	run(cmd)
+	return err
+}
+
+// runHooked runs the command within the hooks, the global ones wrap the ones of the
+// command. If a before hook fails, neither the command nor the after hooks run
+func (c *Cortana) runHooked(cmd *Command) error {
+	args := append([]string{}, c.ctx.args...)
+	for _, hook := range append(append([]func(*Command, []string) error{}, c.beforeRun...), cmd.beforeRun...) {
+		if err := hook(cmd, args); err != nil {
+			return err
+		}
+	}
+	err := cmd.exec()
+	for _, hook := range append(append([]func(*Command, error){}, cmd.afterRun...), c.afterRun...) {
+		hook(cmd, err)
+	}
+	return err
+}
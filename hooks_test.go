@@ -0,0 +1,125 @@
+package cortana
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type nestedDefaults struct {
+	Timeout int `cortana:"--timeout, -, , timeout"`
+}
+
+func (n *nestedDefaults) SetDefaults() {
+	n.Timeout = 30
+}
+
+type workerDefaults struct {
+	Workers int `cortana:"--workers, -w, , workers" env:"CORTANA_TEST_WORKERS"`
+	Retries int `cortana:"--retries, -r, 5, retries"`
+	Nested  nestedDefaults
+}
+
+func (w *workerDefaults) SetDefaults() {
+	w.Workers = 8
+	w.Retries = 1
+}
+
+func TestSetDefaultsOverridden(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(config, []byte(`{"workers": 4}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		config  bool
+		env     string
+		args    []string
+		workers int
+	}{
+		{"code", false, "", nil, 8},
+		{"config", true, "", nil, 4},
+		{"env", true, "3", nil, 3},
+		{"args", true, "3", []string{"--workers", "2"}, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != "" {
+				t.Setenv("CORTANA_TEST_WORKERS", tc.env)
+			}
+			stderr := bytes.NewBuffer(nil)
+			c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+			if tc.config {
+				c.AddConfig(config, UnmarshalFunc(json.Unmarshal))
+			}
+			var opts workerDefaults
+			c.Parse(&opts, WithArgs(append([]string{}, tc.args...)))
+			if stderr.Len() > 0 {
+				t.Fatalf("unexpected error %q", stderr.String())
+			}
+			if opts.Workers != tc.workers {
+				t.Errorf("expected %d workers, got %d", tc.workers, opts.Workers)
+			}
+			// the tag default overrides the code default, the nested struct has its own
+			if opts.Retries != 5 || opts.Nested.Timeout != 30 {
+				t.Errorf("unexpected defaults %+v", opts)
+			}
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+	logged := func(name string) Middleware {
+		return func(next func(cmd *Command)) func(cmd *Command) {
+			return func(cmd *Command) {
+				trace = append(trace, name+" before "+cmd.Path)
+				next(cmd)
+				trace = append(trace, name+" after "+cmd.Path)
+			}
+		}
+	}
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	c.UseMiddleware(logged("first"), logged("second"))
+	c.UseMiddleware(logged("third"))
+	c.AddRootCommand(func() { trace = append(trace, "run root") })
+	c.AddCommand("status", func() { trace = append(trace, "run status "+strings.Join(c.Args(), " ")) }, "status")
+	c.Alias("st", "status -v")
+
+	cases := []struct {
+		name string
+		args []string
+		path string
+		run  string
+	}{
+		{"command", []string{"status"}, "status", "run status "},
+		{"alias", []string{"st"}, "status", "run status -v"},
+		{"root", []string{}, "", "run root"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			trace = nil
+			if err := c.LaunchE(tc.args...); err != nil {
+				t.Fatal(err)
+			}
+			// the alias runs the middlewares once for the command it resolves to
+			want := []string{
+				"first before " + tc.path,
+				"second before " + tc.path,
+				"third before " + tc.path,
+				tc.run,
+				"third after " + tc.path,
+				"second after " + tc.path,
+				"first after " + tc.path,
+			}
+			if !reflect.DeepEqual(trace, want) {
+				t.Errorf("expected %q, got %q", want, trace)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package cortana
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FlagInfo describes a flag
+type FlagInfo struct {
+	Field       string // the field path, like Server.Port
+	Long        string
+	Short       string
+	Default     string
+	Description string
+	Required    bool
+	Type        string // the go type of the field
+	Group       string
+	ConfigKey   string
+	Source      Source // where the value comes from, only available after parsing
+	Value       string // the effective value, masked if Secret, only available after parsing
+
+	Placeholder   string   // the placeholder of the value like <port>, empty if it takes no value
+	OptionalValue bool     // the value is optional and only accepted with '='
+	Negation      string   // the negated form of the bool flag, like --no-cache
+	Choices       []string // the allowed values, any value if empty
+	Min, Max      string   // the bounds of the number, empty if unbounded
+	Hidden        bool     // the flag is parsed but not shown in the usage
+	Secret        bool     // the value is masked in Flags, the records and the dumped config
+	Deprecated    []string // the old names of the flag, which are warned if used
+	Aliases       []string // the other long names of the flag
+	Excludes      []string // the flags which can not be given with the flag
+	Env           string   // the name of the env variable which sets the flag
+	Occurrences   int      // the times the flag is given in the args, only available after parsing
+	Candidates    []string // the values offered by the completion, declared by the complete tag or the choices
+	DefaultText   string   // the default value shown in the usage, only available in the UsageModel
+
+	RequiredIf     []string // required if any of the conditions like --tls or --tls=true holds
+	RequiredUnless []string // required unless any of the flags is set
+}
+
+// ArgInfo describes a positional argument
+type ArgInfo struct {
+	Field       string // the field path, like Server.Port
+	Name        string
+	Default     string
+	Description string
+	Required    bool
+	Variadic    bool // the arg receives all the remaining positional args
+	Type        string
+	Placeholder string // the name in the synopsis, it is the Name unless the tag overrides it
+}
+
+func (f *flag) info() FlagInfo {
+	var placeholder string
+	if f.takesValue() {
+		name := strings.ToLower(f.name)
+		if f.long != "-" {
+			name = strings.TrimLeft(f.long, "-")
+		}
+		placeholder = "<" + name + durationHint(f) + ">"
+		if f.rv.IsValid() && typePlaceholder(f.rv.Type()) != "" {
+			placeholder = typePlaceholder(f.rv.Type())
+		}
+		// an array takes a value for each element, like <size,size> for a [2]int
+		if f.rv.IsValid() && isList(f.rv) && f.rv.Kind() == reflect.Array {
+			placeholder = "<" + strings.TrimSuffix(strings.Repeat(name+",", f.rv.Len()), ",") + ">"
+		}
+		if f.format == "json" {
+			placeholder = "<json>"
+		}
+		if f.placeholder != "" {
+			placeholder = "<" + f.placeholder + ">"
+		}
+	}
+	return FlagInfo{
+		Field:       f.path,
+		Long:        f.long,
+		Short:       f.short,
+		Default:     f.defaultValue,
+		Description: f.description,
+		Required:    f.required,
+		Type:        typeName(f.rv),
+		Group:       f.group,
+		ConfigKey:   f.configKey,
+		Source:      f.source,
+
+		Placeholder:   placeholder,
+		OptionalValue: f.hasOptArg,
+		Negation:      f.negation(),
+		Choices:       f.choices,
+		Min:           f.min,
+		Max:           f.max,
+		Hidden:        f.hidden,
+		Secret:        f.secret,
+		Deprecated:    f.deprecated,
+		Aliases:       f.aliases,
+		Excludes:      f.excludes,
+		Env:           f.env,
+		Occurrences:   f.occurrences,
+		Candidates:    f.candidates,
+
+		RequiredIf:     f.requiredIf,
+		RequiredUnless: f.requiredUnless,
+	}
+}
+
+func (nf *nonflag) info() ArgInfo {
+	name := nf.long
+	if name == "" {
+		name = nf.name
+	}
+	placeholder := name
+	if nf.placeholder != "" {
+		placeholder = nf.placeholder
+	}
+	return ArgInfo{
+		Field:       nf.path,
+		Name:        name,
+		Default:     nf.defaultValue,
+		Description: nf.description,
+		Required:    nf.required,
+		Variadic:    isList(nf.rv) || nf.hasJoin,
+		Type:        typeName(nf.rv),
+		Placeholder: placeholder,
+	}
+}
+
+func typeName(rv reflect.Value) string {
+	if !rv.IsValid() {
+		return ""
+	}
+	return rv.Type().String()
+}
+
+// Inspect returns the flags and args described by the cortana tags of v without
+// parsing, v is never modified and no default value is applied. The tags are
+// validated, so it is handy to lint the option structs in unit tests
+func Inspect(v interface{}) ([]FlagInfo, []ArgInfo, error) {
+	return inspect(v, tagOptions{})
+}
+
+func inspect(v interface{}, opts tagOptions) ([]FlagInfo, []ArgInfo, error) {
+	rt := reflect.TypeOf(v)
+	for rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("cortana: Inspect requires a struct, got %T", v)
+	}
+
+	flags, nonflags, err := parseTags(reflect.New(rt), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	owners := make(map[*flag]string)
+	for _, f := range flags {
+		owners[f] = f.path
+	}
+	if err := (&Cortana{tags: opts}).checkDuplicates(flags, owners); err != nil {
+		return nil, nil, err
+	}
+	var finfos []FlagInfo
+	var ainfos []ArgInfo
+	for _, f := range flags {
+		if err := validateFlag(f); err != nil {
+			return nil, nil, err
+		}
+		finfos = append(finfos, f.info())
+	}
+	for _, nf := range nonflags {
+		if err := validateFlag((*flag)(nf)); err != nil {
+			return nil, nil, err
+		}
+		ainfos = append(ainfos, nf.info())
+	}
+	return finfos, ainfos, nil
+}
+
+// validateFlag checks the syntax of the tag and its default value
+func validateFlag(f *flag) error {
+	var err error
+	switch {
+	case strings.ContainsAny(f.long, " \t"):
+		err = errors.New("long name " + f.long + " contains spaces")
+	case strings.HasPrefix(f.long, "-") && f.long != "-" && !strings.HasPrefix(f.long, "--"):
+		err = errors.New("long name " + f.long + " should start with --")
+	case f.short != "" && f.short != "-" && (!strings.HasPrefix(f.short, "-") || strings.HasPrefix(f.short, "--")):
+		err = errors.New("short name " + f.short + " should start with a single -")
+	case strings.ContainsAny(f.short, " \t"):
+		err = errors.New("short name " + f.short + " contains spaces")
+	default:
+		if err = f.compilePattern(); err == nil {
+			err = f.parseBounds()
+		}
+	}
+	if err == nil && f.defaultValue != "" && f.defaultFunc == nil && !(f.rv.Kind() == reflect.Slice && f.defaultValue == "nil") {
+		v := reflect.New(f.rv.Type()).Elem()
+		if e := applyDefault(f, v); e != nil {
+			err = fmt.Errorf("invalid default value %q: %v", f.defaultValue, e)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cortana: field %s: %v", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package cortana
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInspectMalformedTag(t *testing.T) {
+	cases := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"unknown key", &struct {
+			Name string `cortana:"long=--name color=red"`
+		}{}, "unknown key color"},
+		{"unterminated quote", &struct {
+			Name string `cortana:"long=--name desc='who to greet"`
+		}{}, "unterminated quote"},
+		{"nested", &struct {
+			Server struct {
+				Port int `cortana:"long=--port long=--listen"`
+			}
+		}{}, "field Server.Port"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer func() {
+				if v := recover(); v != nil {
+					t.Fatalf("Inspect panics: %v", v)
+				}
+			}()
+			_, _, err := Inspect(c.v)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("expected an error with %q, got %v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestParseMalformedTagPanics(t *testing.T) {
+	defer func() {
+		if v := recover(); v == nil {
+			t.Error("expected Parse to panic on a malformed tag")
+		}
+	}()
+	opts := struct {
+		Name string `cortana:"long=--name color=red"`
+	}{}
+	parseArgs(t, &opts)
+}
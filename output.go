@@ -0,0 +1,229 @@
+package cortana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// the formats supported by Print, the first one is the default
+var outputFormats = []string{"table", "json", "yaml"}
+
+// OutputFlag adds the --output, -o flag to all the commands, which selects the
+// format of Print
+func OutputFlag() Option {
+	return func(c *Cortana) {
+		c.predefined.output.long = "--output"
+		c.predefined.output.short = "-o"
+		c.predefined.output.desc = "output format, one of " + strings.Join(outputFormats, ", ")
+	}
+}
+
+// completeOutputFormats lists the formats with the prefix
+func completeOutputFormats(prefix string) []string {
+	var formats []string
+	for _, format := range outputFormats {
+		if strings.HasPrefix(format, prefix) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// setOutput selects the format of Print
+func (c *Cortana) setOutput(format string) error {
+	if !containsString(outputFormats, format) {
+		return fmt.Errorf("unknown output format %q, one of %s", format, strings.Join(outputFormats, ", "))
+	}
+	c.output = format
+	return nil
+}
+
+// Print writes v to the stdout in the format selected by the output flag. A slice of
+// structs is rendered as a table by default, the headers are the names of the fields
+// or given by the "table" tag, and a field tagged with table:"-" is omitted
+func (c *Cortana) Print(v interface{}) {
+	format := c.output
+	if format == "" {
+		format = outputFormats[0]
+	}
+	var err error
+	switch format {
+	case "json":
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(v)
+	case "yaml":
+		err = writeYAML(c.stdout, v)
+	default:
+		err = writeTable(c.stdout, v, c.tags)
+	}
+	if err != nil {
+		c.fatal(err)
+	}
+}
+
+// writeTable writes the structs as the rows of a table, other values are written as they are.
+// The "table" tag is looked up like the other modifiers, so a prefixed one like "cli-table" wins
+func writeTable(w io.Writer, v interface{}, opts tagOptions) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	rows := rv
+	if rv.Kind() == reflect.Struct {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rv.Type()), 0, 1), rv)
+	}
+	if rows.Kind() != reflect.Slice && rows.Kind() != reflect.Array {
+		_, err := fmt.Fprintln(w, v)
+		return err
+	}
+	rt := rows.Type().Elem()
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct {
+		for i := 0; i < rows.Len(); i++ {
+			if _, err := fmt.Fprintln(w, rows.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var fields []int
+	var headers []string
+	for i := 0; i < rt.NumField(); i++ {
+		ft := rt.Field(i)
+		header := opts.get(ft.Tag, "table")
+		if ft.PkgPath != "" || header == "-" {
+			continue
+		}
+		if header == "" {
+			header = strings.ToUpper(ft.Name)
+		}
+		fields = append(fields, i)
+		headers = append(headers, header)
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		for row.Kind() == reflect.Ptr && !row.IsNil() {
+			row = row.Elem()
+		}
+		if row.Kind() != reflect.Struct {
+			continue
+		}
+		cells := make([]string, len(fields))
+		for j, field := range fields {
+			cells[j] = fmt.Sprint(row.Field(field).Interface())
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeYAML writes v as yaml, it is encoded as json first so the json tags and
+// marshalers are honored
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(nil)
+	yamlLines(buf, generic, "")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// yamlLines renders the value decoded from json as the lines of yaml
+func yamlLines(w *bytes.Buffer, v interface{}, indent string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			w.WriteString(indent + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if yamlBlock(v[k]) {
+				w.WriteString(indent + yamlScalar(k) + ":\n")
+				yamlLines(w, v[k], indent+"  ")
+			} else {
+				w.WriteString(indent + yamlScalar(k) + ": " + yamlInline(v[k]) + "\n")
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			w.WriteString(indent + "[]\n")
+			return
+		}
+		for _, e := range v {
+			if yamlBlock(e) {
+				w.WriteString(indent + "-\n")
+				yamlLines(w, e, indent+"  ")
+			} else {
+				w.WriteString(indent + "- " + yamlInline(e) + "\n")
+			}
+		}
+	default:
+		w.WriteString(indent + yamlInline(v) + "\n")
+	}
+}
+
+// yamlBlock reports if the value is rendered in lines of its own
+func yamlBlock(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	}
+	return false
+}
+
+// yamlInline renders the scalars and the empty collections
+func yamlInline(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	case string:
+		return yamlScalar(v)
+	}
+	return fmt.Sprint(v)
+}
+
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_./-]*$`)
+
+// yamlScalar quotes the string unless it is plain and can not be read as another type
+func yamlScalar(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "y", "n":
+		return `"` + s + `"`
+	}
+	if yamlPlain.MatchString(s) {
+		return s
+	}
+	return fmt.Sprintf("%q", s)
+}
@@ -0,0 +1,26 @@
+package cortana
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPrintTableTag(t *testing.T) {
+	type row struct {
+		Name   string `table:"NAME" cli-table:"HOST"`
+		Port   int    `cli-table:"-"`
+		Status string
+	}
+	stdout := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(stdout), WithStderr(io.Discard), WithTagName("cli"))
+	c.Print([]row{{"db", 5432, "up"}})
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || strings.Fields(lines[0])[0] != "HOST" || strings.Contains(lines[0], "PORT") {
+		t.Errorf("expected the headers by the cli-table tag, got %q", stdout.String())
+	}
+	if got := strings.Fields(lines[1]); len(got) != 2 || got[0] != "db" || got[1] != "up" {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+}
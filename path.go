@@ -0,0 +1,35 @@
+package cortana
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// normalizePath expands the leading '~' to the home directory and %VAR% on windows,
+// then converts the slashes to the separator of the platform. A '$' is literal, and
+// UNC paths like \\server\share are kept as is
+func normalizePath(path string) (string, error) {
+	if path == "" {
+		return path, nil
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.New("can not expand ~ in " + path + ": " + err.Error())
+		}
+		path = home + path[1:]
+	}
+	path = expandEnv(path)
+	return filepath.FromSlash(path), nil
+}
+
+// expandHome expands the path like normalizePath, the path is returned unchanged
+// if it can not be expanded
+func expandHome(path string) string {
+	if p, err := normalizePath(path); err == nil {
+		return p
+	}
+	return path
+}
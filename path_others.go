@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package cortana
+
+// expandEnv keeps the path as is, the shell has expanded the variables of the args
+// and a '$' in a configured path is literal
+func expandEnv(path string) string {
+	return path
+}
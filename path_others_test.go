@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package cortana
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	t.Setenv("CORTANA_DIR", "/etc/cortana")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"~/config.yaml", home + "/config.yaml"},
+		{"~", home},
+		{"~other/config.yaml", "~other/config.yaml"},
+		// a '$' is literal rather than an env variable
+		{"$CORTANA_DIR/config.yaml", "$CORTANA_DIR/config.yaml"},
+		{"/srv/${CORTANA_DIR}/$1.yaml", "/srv/${CORTANA_DIR}/$1.yaml"},
+		{"%CORTANA_DIR%/config.yaml", "%CORTANA_DIR%/config.yaml"},
+	}
+	for _, c := range cases {
+		got, err := normalizePath(c.path)
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.path, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package cortana
+
+import (
+	"os"
+	"strings"
+)
+
+// expandEnv expands %VAR% like cmd.exe does, an undefined %VAR% is kept as is
+func expandEnv(path string) string {
+	b := &strings.Builder{}
+	for {
+		begin := strings.Index(path, "%")
+		if begin < 0 {
+			break
+		}
+		end := strings.Index(path[begin+1:], "%")
+		if end < 0 {
+			break
+		}
+		end += begin + 1
+		name := path[begin+1 : end]
+		if v, ok := os.LookupEnv(name); ok && name != "" {
+			b.WriteString(path[:begin] + v)
+		} else {
+			b.WriteString(path[:end])
+			path = path[end:]
+			continue
+		}
+		path = path[end+1:]
+	}
+	b.WriteString(path)
+	return b.String()
+}
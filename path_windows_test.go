@@ -0,0 +1,42 @@
+//go:build windows
+// +build windows
+
+package cortana
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNormalizePathWindows(t *testing.T) {
+	t.Setenv("CORTANA_DIR", `C:\Users\cortana`)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path string
+		want string
+	}{
+		{`%CORTANA_DIR%\config.yaml`, `C:\Users\cortana\config.yaml`},
+		{`$CORTANA_DIR/config.yaml`, `$CORTANA_DIR\config.yaml`},
+		{`%CORTANA_UNDEFINED%\config.yaml`, `%CORTANA_UNDEFINED%\config.yaml`},
+		{`100%\config.yaml`, `100%\config.yaml`},
+		{`C:/Program Files/cortana/config.yaml`, `C:\Program Files\cortana\config.yaml`},
+		{`D:\config.yaml`, `D:\config.yaml`},
+		{`\\server\share\config.yaml`, `\\server\share\config.yaml`},
+		{`//server/share/config.yaml`, `\\server\share\config.yaml`},
+		{`~\config.yaml`, home + `\config.yaml`},
+		{`~/config.yaml`, home + `\config.yaml`},
+	}
+	for _, c := range cases {
+		got, err := normalizePath(c.path)
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.path, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cortana
+
+import (
+	stdctx "context"
+	"errors"
+	"strings"
+)
+
+// skipPreflightFlag skips all the preflight checks, it is hidden from the usage
+const skipPreflightFlag = "--skip-preflight"
+
+// preflight checks a need of the commands before they run
+type preflight struct {
+	need   string
+	check  func(ctx stdctx.Context) error
+	passed bool // the check has succeeded, it is not run again if the checks are cached
+}
+
+// WithPreflight registers a check for the commands annotated with the need, like
+// "network" or "credentials:aws". The checks run before the command in the order of
+// its annotations with the ctx of LaunchContext, the errors are reported together
+// and the command is not run. The hidden flag --skip-preflight skips all the checks
+// for emergencies
+func WithPreflight(need string, check func(ctx stdctx.Context) error) Option {
+	return func(c *Cortana) {
+		c.preflights = append(c.preflights, preflight{need: need, check: check})
+	}
+}
+
+// CachePreflights runs every check until it succeeds once, so the commands launched
+// again by the same commander, like the ones typed in a REPL session, are not
+// checked again
+func CachePreflights() Option {
+	return func(c *Cortana) {
+		c.cachePreflights = true
+	}
+}
+
+// Annotations declares the needs of the command, which are checked by the
+// preflights before it runs
+func Annotations(needs ...string) CommandOption {
+	return func(cmd *Command) {
+		cmd.Annotations = append(cmd.Annotations, needs...)
+	}
+}
+
+// runPreflights runs the checks for the needs of the command, the skip flag is
+// removed from the args
+func (c *Cortana) runPreflights(ctx stdctx.Context, cmd *Command) error {
+	if len(c.preflights) == 0 {
+		return nil
+	}
+	var skip bool
+	c.ctx.args, skip = c.stripSkipPreflight(cmd, c.ctx.args)
+	if skip {
+		return nil
+	}
+
+	var errs []string
+	for _, need := range cmd.Annotations {
+		for i, p := range c.preflights {
+			if p.need != need || (c.cachePreflights && p.passed) {
+				continue
+			}
+			if err := p.check(ctx); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			c.preflights[i].passed = true
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// stripSkipPreflight removes the skip flag in the flag position, it is kept if it is
+// the value of the flag before it or after "--", so the args are never changed by
+// accident. The flags are known if the command is added with the WithFlags option,
+// an unknown flag without "=" is assumed to take the value
+func (c *Cortana) stripSkipPreflight(cmd *Command, args []string) ([]string, bool) {
+	ctx := c.contextOf(cmd.Path)
+	flags := append(append([]*flag{}, ctx.desc.flags...), ctx.desc.predefined...)
+	var skip bool
+	stripped := args[:0:0]
+	for i, arg := range args {
+		if arg == "--" {
+			stripped = append(stripped, args[i:]...)
+			break
+		}
+		if arg == skipPreflightFlag && (i == 0 || !takesNextArg(flags, args[i-1])) {
+			skip = true
+			continue
+		}
+		stripped = append(stripped, arg)
+	}
+	return stripped, skip
+}
+
+// takesNextArg reports if the arg is a flag whose value is the next arg
+func takesNextArg(flags []*flag, arg string) bool {
+	if !strings.HasPrefix(arg, "-") || arg == "-" || arg == skipPreflightFlag || strings.Contains(arg, "=") {
+		return false
+	}
+	for _, f := range flags {
+		if arg == f.long || arg == f.short || containsString(f.aliases, arg) {
+			return f.takesValue() && !f.hasOptArg
+		}
+		if arg == f.negation() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package cortana
+
+import (
+	"bytes"
+	stdctx "context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestSkipPreflight(t *testing.T) {
+	type options struct {
+		Msg     string `cortana:"--msg, -m, , message"`
+		Verbose bool   `cortana:"--verbose, -v, false, verbose"`
+	}
+	cases := []struct {
+		name    string
+		args    []string
+		checked bool
+		rest    []string
+	}{
+		{"flag", []string{"--skip-preflight", "a"}, false, []string{"a"}},
+		{"after a bool flag", []string{"-v", "--skip-preflight"}, false, []string{"-v"}},
+		{"value of a flag", []string{"--msg", "--skip-preflight"}, true, []string{"--msg", "--skip-preflight"}},
+		{"after --", []string{"--", "--skip-preflight"}, true, []string{"--", "--skip-preflight"}},
+		{"value of an unknown flag", []string{"--other", "--skip-preflight"}, true, []string{"--other", "--skip-preflight"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var checked bool
+			c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(bytes.NewBuffer(nil)),
+				WithPreflight("network", func(ctx stdctx.Context) error {
+					checked = true
+					return errors.New("network is down")
+				}))
+			var rest []string
+			c.AddCommand("sync", func() {
+				rest = c.Args()
+			}, "sync", Annotations("network"), WithFlags(&options{}))
+
+			err := c.LaunchE(append([]string{"sync"}, tc.args...)...)
+			if checked != tc.checked {
+				t.Fatalf("expected checked %v, got %v", tc.checked, checked)
+			}
+			if tc.checked {
+				if err == nil {
+					t.Error("expected the error of the preflight")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rest, tc.rest) {
+				t.Errorf("expected the args %q, got %q", tc.rest, rest)
+			}
+		})
+	}
+}
+
+func TestStripSkipPreflightKeepsArgs(t *testing.T) {
+	c := New(ExitOnError(false))
+	c.AddCommand("echo", func() {}, "echo", WithFlags(&struct {
+		Msg string `cortana:"--msg, -m, , message"`
+	}{}))
+	args := []string{"--msg", "--skip-preflight", "--", "--skip-preflight"}
+	stripped, skip := c.stripSkipPreflight(&Command{Path: "echo"}, args)
+	if skip || !reflect.DeepEqual(stripped, args) {
+		t.Errorf("expected the args untouched, got %q, skip %v", stripped, skip)
+	}
+}
+
+func TestPreflightContextAndCache(t *testing.T) {
+	type key struct{}
+	for _, cache := range []bool{false, true} {
+		var checks, failed int
+		opts := []Option{ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard),
+			WithPreflight("network", func(ctx stdctx.Context) error {
+				checks++
+				if ctx.Value(key{}) != "session" {
+					t.Errorf("expected the ctx of the launch, got %v", ctx)
+				}
+				return nil
+			}),
+			WithPreflight("credentials:aws", func(ctx stdctx.Context) error {
+				failed++
+				return errors.New("aws credentials not found: run 'mytool login'")
+			})}
+		if cache {
+			opts = append(opts, CachePreflights())
+		}
+		c := New(opts...)
+		c.AddCommand("sync", func() {}, "sync", Annotations("network"))
+		c.AddCommand("deploy", func() {}, "deploy", Annotations("network", "credentials:aws"))
+
+		ctx := stdctx.WithValue(stdctx.Background(), key{}, "session")
+		for i := 0; i < 2; i++ {
+			if err := c.LaunchContext(ctx, "sync"); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.LaunchContext(ctx, "deploy"); err == nil {
+				t.Error("expected the error of the aws credentials")
+			}
+		}
+		// the failed checks are always run again
+		want := 4
+		if cache {
+			want = 1
+		}
+		if checks != want || failed != 2 {
+			t.Errorf("cache %v: expected %d network checks and 2 failed, got %d and %d", cache, want, checks, failed)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package cortana
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// rcfile holds the default args of a user, the format is like
+//
+//	# args for all the commands
+//	--output json
+//
+//	# args for a certain command
+//	[db migrate] --dry-run
+//	--verbose
+//
+// a section header applies to the lines following it until another header
+type rcfile struct {
+	global   []string
+	sections map[string][]string
+}
+
+// args returns the default args of the command
+func (rc *rcfile) args(path string) []string {
+	var args []string
+	args = append(args, rc.global...)
+	args = append(args, rc.sections[path]...)
+	return args
+}
+
+// searchRCFile searches the command again with the args of the rc file placed right
+// after the words of the command matched by the args, so the rc args take part in
+// the resolution like typed ones and the args typed by the user come last to win
+func (c *Cortana) searchRCFile(cmd *command, ctx context) (*command, context, error) {
+	rc, err := loadRCFile(c.rcfile)
+	if err != nil {
+		return nil, context{}, err
+	}
+	rcArgs := rc.args(cmd.Path)
+	if len(rcArgs) == 0 {
+		return cmd, ctx, nil
+	}
+	c.tracef("the args %q of %s are added to %q", rcArgs, c.rcfile, cmd.Path)
+	args := append(strings.Fields(cmd.Path), rcArgs...)
+	resolved, rctx := c.searchCommand(append(args, ctx.args...))
+	// the args left to the command are the rc ones followed by the typed ones
+	if n := len(rctx.args) - len(ctx.args); n > 0 {
+		rctx.rcArgs = n
+	}
+	return resolved, rctx, nil
+}
+
+// loadRCFile reads the rc file, a missing file is treated as an empty one
+func loadRCFile(path string) (*rcfile, error) {
+	rc := &rcfile{sections: make(map[string][]string)}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rc, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	section := ""
+	global := true
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: unterminated section header", path, n)
+			}
+			section = strings.Join(strings.Fields(line[1:end]), " ")
+			global = false
+			line = line[end+1:]
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if global {
+			rc.global = append(rc.global, args...)
+		} else {
+			rc.sections[section] = append(rc.sections[section], args...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rc, nil
+}
+
+// splitArgs splits the line into args like a shell, quotes and backslash
+// escapes are supported and a word starting with '#' begins a comment
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var quote rune
+	var escaped, inArg bool
+	arg := &strings.Builder{}
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '#' && !inArg:
+			return args, nil
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
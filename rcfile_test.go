@@ -0,0 +1,112 @@
+package cortana
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newRCCortana returns a cortana with the rc file of the content
+func newRCCortana(t *testing.T, content string) *Cortana {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".testrc")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return New(ExitOnError(false), WithStdout(io.Discard), WithStderr(bytes.NewBuffer(nil)), WithRCFile(path))
+}
+
+func TestRCFileArgs(t *testing.T) {
+	c := newRCCortana(t, "--output json\n[db migrate] --dry-run --tag rc\n")
+	var opts struct {
+		Output string   `cortana:"--output, -o, text, output format"`
+		DryRun bool     `cortana:"--dry-run, -, false, dry run"`
+		Tags   []string `cortana:"--tag, -t, , tags"`
+	}
+	var sources map[string]Source
+	c.AddCommand("db migrate", func() {
+		c.Parse(&opts)
+		sources = make(map[string]Source)
+		for _, f := range c.Flags() {
+			sources[f.Long] = f.Source
+		}
+	}, "migrate")
+
+	if err := c.LaunchE("db", "migrate", "--tag", "typed"); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Output != "json" || !opts.DryRun {
+		t.Errorf("the rc args are not applied: %+v", opts)
+	}
+	// the typed values replace the ones from the rc file
+	if !reflect.DeepEqual(opts.Tags, []string{"typed"}) {
+		t.Errorf("expected the typed tags, got %q", opts.Tags)
+	}
+	if sources["--output"].Kind != SourceRCFile || sources["--dry-run"].Kind != SourceRCFile {
+		t.Errorf("expected the rc file as the source, got %+v", sources)
+	}
+	if sources["--tag"].Kind != SourceArg {
+		t.Errorf("expected the args as the source of --tag, got %+v", sources["--tag"])
+	}
+}
+
+func TestRCFileTypedArgsWin(t *testing.T) {
+	c := newRCCortana(t, "--output json\n")
+	var opts struct {
+		Output string `cortana:"--output, -o, text, output format"`
+	}
+	c.AddCommand("show", func() {
+		c.Parse(&opts, OnRepeat(RepeatError))
+	}, "show")
+	if err := c.LaunchE("show", "-o", "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Output != "yaml" {
+		t.Errorf("expected yaml, got %q", opts.Output)
+	}
+}
+
+func TestRCFileResolution(t *testing.T) {
+	c := newRCCortana(t, "[db] migrate\n--verbose\n")
+	c.AddCommand("db", func() {}, "db")
+	c.AddCommand("db migrate", func() {}, "migrate")
+
+	// a section takes part in the resolution like the typed args
+	r := c.Resolve([]string{"db", "--force"})
+	if r.Command == nil || r.Command.Path != "db migrate" {
+		t.Fatalf("expected db migrate, got %+v", r.Command)
+	}
+	if !reflect.DeepEqual(r.RCArgs, []string{"--verbose"}) {
+		t.Errorf("expected the rc args [--verbose], got %q", r.RCArgs)
+	}
+	if !reflect.DeepEqual(r.Flags, []string{"--verbose", "--force"}) {
+		t.Errorf("expected the rc args before the typed ones, got %q", r.Flags)
+	}
+}
+
+func TestRCFileExplain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".testrc")
+	if err := ioutil.WriteFile(path, []byte("[db migrate] --dry-run --tag 'from rc'\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdout := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(stdout), WithStderr(io.Discard), WithRCFile(path), ExplainFlag("--explain", ""))
+	var ran bool
+	c.AddCommand("db migrate", func() { ran = true }, "migrate")
+
+	if err := c.LaunchE("db", "migrate", "--explain", "--tag", "typed", "--", "--explain"); err != nil {
+		t.Fatal(err)
+	}
+	if ran {
+		t.Error("the command is run with --explain")
+	}
+	want := "command:  db migrate (exact match)\n" +
+		"rc args:  --dry-run --tag \"from rc\" (from " + path + ")\n" +
+		"args:     --tag typed -- --explain\n"
+	if stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+}
@@ -0,0 +1,176 @@
+package cortana
+
+import (
+	stdctx "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// recordVersion is the version of the record format
+const recordVersion = 1
+
+// Record describes an invocation, it is written if the env named by RecordEnv is set
+// and can be replayed to reproduce the invocation
+type Record struct {
+	Version  int            `json:"version"`
+	Command  string         `json:"command"`
+	Args     []string       `json:"args"`
+	RCArgs   []string       `json:"rcArgs,omitempty"` // the args added from the rc file
+	Flags    []RecordFlag   `json:"flags,omitempty"`
+	Configs  []RecordConfig `json:"configs,omitempty"`
+	Duration string         `json:"duration"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// RecordFlag is the effective value of a flag and where it comes from
+type RecordFlag struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source Source `json:"source"`
+}
+
+// RecordConfig is a configuration file which has been read
+type RecordConfig struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// recorder writes the record of the invocation
+type recorder struct {
+	env    string // the env naming the path of the record
+	path   string
+	start  time.Time
+	record *Record
+}
+
+// RecordEnv records the invocation to the file named by the env, like
+// MYAPP_RECORD=/tmp/trace.json, see Replay
+func RecordEnv(name string) Option {
+	return func(c *Cortana) {
+		c.recorder.env = name
+	}
+}
+
+// startRecord starts recording if the env is set
+func (c *Cortana) startRecord(path string, args []string) {
+	if c.recorder.env == "" {
+		return
+	}
+	file := os.Getenv(c.recorder.env)
+	if file == "" {
+		return
+	}
+	c.recorder.path = file
+	c.recorder.start = time.Now()
+	c.recorder.record = &Record{Version: recordVersion, Command: path, Args: args,
+		RCArgs: append([]string(nil), c.ctx.args[:c.ctx.rcArgs]...)}
+}
+
+// recordConfig records the content hash of a configuration file
+func (c *Cortana) recordConfig(path string, data []byte) {
+	if c.recorder.record == nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	cfg := RecordConfig{Path: path, SHA256: hex.EncodeToString(sum[:])}
+	// the configurations are read again if the parse restarts
+	for i := range c.recorder.record.Configs {
+		if c.recorder.record.Configs[i].Path == path {
+			c.recorder.record.Configs[i] = cfg
+			return
+		}
+	}
+	c.recorder.record.Configs = append(c.recorder.record.Configs, cfg)
+}
+
+// finishRecord writes the record with the flags of the last parse and the error
+func (c *Cortana) finishRecord(err error) {
+	r := c.recorder.record
+	if r == nil {
+		return
+	}
+	c.recorder.record = nil
+	r.Duration = time.Since(c.recorder.start).String()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	for _, f := range c.parsingFlags() {
+		if !f.rv.IsValid() || !f.rv.CanInterface() {
+			continue
+		}
+		name := f.displayName()
+		if name == "" || name == "-" {
+			name = f.name
+		}
+		r.Flags = append(r.Flags, RecordFlag{Name: name, Value: f.valueText(), Source: f.source})
+	}
+	data, e := json.MarshalIndent(r, "", "  ")
+	if e == nil {
+		e = ioutil.WriteFile(c.recorder.path, data, 0600)
+	}
+	if e != nil {
+		fmt.Fprintln(c.stderr, "cortana: record:", e)
+	}
+}
+
+// ReadRecord reads the record written by RecordEnv
+func ReadRecord(path string) (*Record, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Record{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if r.Version > recordVersion {
+		return nil, fmt.Errorf("%s: unsupported record version %d", path, r.Version)
+	}
+	return r, nil
+}
+
+// Replay launches the recorded invocation again, it warns if the command or the
+// flags differ from the recorded ones. The values from the configuration files and
+// envs are not replayed, a changed configuration file is warned
+func (c *Cortana) Replay(r *Record) {
+	res := c.Resolve(r.Args)
+	switch {
+	case res.Command == nil:
+		fmt.Fprintf(c.stderr, "warning: replay: the command %q is not found\n", r.Command)
+	case res.Command.Path != r.Command:
+		fmt.Fprintf(c.stderr, "warning: replay: the args run %q instead of %q\n", res.Command.Path, r.Command)
+	default:
+		if m, err := c.UsageModelOf(r.Command); err == nil && len(m.Flags) > 0 {
+			names := make(map[string]bool)
+			for _, f := range m.Flags {
+				names[f.Long], names[f.Short] = true, true
+			}
+			for _, f := range r.Flags {
+				if !names[f.Name] && len(f.Name) > 0 && f.Name[0] == '-' {
+					fmt.Fprintf(c.stderr, "warning: replay: the flag %s is not defined any more\n", f.Name)
+				}
+			}
+		}
+	}
+	if res.Command != nil && strings.Join(res.RCArgs, " ") != strings.Join(r.RCArgs, " ") {
+		fmt.Fprintf(c.stderr, "warning: replay: the rc file adds %q instead of %q\n", res.RCArgs, r.RCArgs)
+	}
+	for _, cfg := range r.Configs {
+		data, err := ioutil.ReadFile(cfg.Path)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "warning: replay: %v\n", err)
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != cfg.SHA256 {
+			fmt.Fprintf(c.stderr, "warning: replay: %s has changed since recorded\n", cfg.Path)
+		}
+	}
+	// the recorded args are replayed as they are, even if there is none
+	c.report(c.launch(stdctx.Background(), r.Args))
+}
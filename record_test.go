@@ -0,0 +1,185 @@
+package cortana
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type migrateOptions struct {
+	DryRun bool   `cortana:"--dry-run, -, false, dry run"`
+	Target string `cortana:"--target, -t, latest, the target version"`
+	Table  string `cortana:"--table, -, , the table"`
+}
+
+// newRecordCortana returns a cortana recording to the file in the env
+func newRecordCortana(t *testing.T, stderr io.Writer) (*Cortana, *migrateOptions) {
+	t.Helper()
+	opts := &migrateOptions{}
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr), RecordEnv("CORTANA_TEST_RECORD"))
+	c.AddCommand("db migrate", func() {
+		c.Parse(opts)
+	}, "migrate", WithFlags(&migrateOptions{}))
+	return c, opts
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "trace.json")
+	cfg := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{"table": "users"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CORTANA_TEST_RECORD", path)
+
+	c, _ := newRecordCortana(t, io.Discard)
+	c.AddConfig(cfg, UnmarshalFunc(json.Unmarshal))
+	if err := c.LaunchE("db", "migrate", "--dry-run"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadRecord(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Version != recordVersion || r.Command != "db migrate" || r.Duration == "" || r.Error != "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	// the args are replayed as they are typed, the command path included
+	if want := []string{"db", "migrate", "--dry-run"}; !reflect.DeepEqual(r.Args, want) {
+		t.Errorf("expected the args %q, got %q", want, r.Args)
+	}
+	flags := make(map[string]RecordFlag)
+	for _, f := range r.Flags {
+		flags[f.Name] = f
+	}
+	want := map[string]struct {
+		value string
+		kind  string
+	}{
+		"--dry-run": {"true", SourceArg},
+		"--target":  {"latest", SourceDefault},
+		"--table":   {"users", SourceConfig},
+	}
+	for name, w := range want {
+		if f := flags[name]; f.Value != w.value || f.Source.Kind != w.kind {
+			t.Errorf("%s: expected %s from %s, got %+v", name, w.value, w.kind, f)
+		}
+	}
+	if len(r.Configs) != 1 || r.Configs[0].Path != cfg || len(r.Configs[0].SHA256) != 64 {
+		t.Errorf("unexpected configs %+v", r.Configs)
+	}
+
+	// nothing is recorded without the env
+	t.Setenv("CORTANA_TEST_RECORD", "")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, _ = newRecordCortana(t, io.Discard)
+	if err := c.LaunchE("db", "migrate"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(path); len(data) != 0 {
+		t.Errorf("expected no record, got %s", data)
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+	t.Setenv("CORTANA_TEST_RECORD", path)
+	c, _ := newRecordCortana(t, io.Discard)
+	c.LaunchE("db", "migrate", "--unknown")
+	r, err := ReadRecord(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(r.Error, "unknown argument: --unknown") {
+		t.Errorf("expected the error recorded, got %q", r.Error)
+	}
+}
+
+func TestReadRecordVersion(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"current", `{"version": 1, "command": "db migrate"}`, ""},
+		{"newer", `{"version": 2, "command": "db migrate"}`, "unsupported record version 2"},
+		{"malformed", `{"version":`, "unexpected end of JSON input"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".json")
+			if err := ioutil.WriteFile(path, []byte(tc.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			r, err := ReadRecord(path)
+			if tc.err == "" {
+				if err != nil || r.Command != "db migrate" {
+					t.Errorf("unexpected record %+v, error %v", r, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected an error with %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{"table": "orders"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		record  Record
+		target  string
+		warning string
+	}{
+		{"same surface", Record{Version: 1, Command: "db migrate", Args: []string{"db", "migrate", "-t", "v2"},
+			Flags: []RecordFlag{{Name: "--target", Value: "v2", Source: Source{Kind: SourceArg}}}}, "v2", ""},
+		{"removed flag", Record{Version: 1, Command: "db migrate", Args: []string{"db", "migrate", "-t", "v2"},
+			Flags: []RecordFlag{{Name: "--force", Value: "true", Source: Source{Kind: SourceArg}}}},
+			"v2", "the flag --force is not defined any more"},
+		{"moved command", Record{Version: 1, Command: "migrate", Args: []string{"db", "migrate", "-t", "v2"}},
+			"v2", `the args run "db migrate" instead of "migrate"`},
+		{"changed config", Record{Version: 1, Command: "db migrate", Args: []string{"db", "migrate", "-t", "v2"},
+			Configs: []RecordConfig{{Path: cfg, SHA256: strings.Repeat("0", 64)}}},
+			"v2", cfg + " has changed since recorded"},
+		// the args of the process are never taken for the empty ones
+		{"no args", Record{Version: 1, Command: ""}, "", `the command "" is not found`},
+	}
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"prog", "db", "migrate", "-t", "v2"}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr := bytes.NewBuffer(nil)
+			c, opts := newRecordCortana(t, stderr)
+			c.Replay(&tc.record)
+			// the recorded args are launched even with the warnings
+			if opts.Target != tc.target {
+				t.Errorf("expected the target %q replayed, got %q", tc.target, opts.Target)
+			}
+			if tc.warning == "" {
+				if stderr.Len() > 0 {
+					t.Errorf("unexpected warning %q", stderr.String())
+				}
+				return
+			}
+			if !strings.Contains(stderr.String(), "warning: replay: "+tc.warning) {
+				t.Errorf("expected the warning %q, got %q", tc.warning, stderr.String())
+			}
+		})
+	}
+}
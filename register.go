@@ -0,0 +1,48 @@
+package cortana
+
+// Titler is optionally implemented by the options struct of Register to set the
+// title of the command in the usage
+type Titler interface {
+	Title() string
+}
+
+// Describer is optionally implemented by the options struct of Register to set
+// the description of the command in the usage
+type Describer interface {
+	Description() string
+}
+
+// Briefer is optionally implemented by the options struct of Register to give the
+// brief of the command if it is not given at registration
+type Briefer interface {
+	Brief() string
+}
+
+// Register adds a command whose options are parsed into a fresh T before run is
+// called with them, the configs and envs are honored as Parse does. The flags of T
+// are bound like WithFlags, so the usage is known without running the command. A
+// failure of parsing is returned by LaunchE without calling run
+func Register[T any](path string, run func(opts *T) error, brief string, opts ...CommandOption) {
+	RegisterTo(c, path, run, brief, opts...)
+}
+
+// RegisterTo adds the command like Register to the commander c
+func RegisterTo[T any](c *Cortana, path string, run func(opts *T) error, brief string, opts ...CommandOption) {
+	if b, ok := any(new(T)).(Briefer); ok && brief == "" {
+		brief = b.Brief()
+	}
+	proc := func() error {
+		v := new(T)
+		if t, ok := any(v).(Titler); ok {
+			c.Title(t.Title())
+		}
+		if d, ok := any(v).(Describer); ok {
+			c.Description(d.Description())
+		}
+		if err := c.parseE(v); err != nil {
+			return err
+		}
+		return run(v)
+	}
+	c.AddCommandE(path, proc, brief, append([]CommandOption{WithFlags(new(T))}, opts...)...)
+}
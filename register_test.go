@@ -0,0 +1,41 @@
+package cortana
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+type runOptions struct {
+	N int `cortana:"--num, -n, 1, the number"`
+}
+
+func TestRegisterTo(t *testing.T) {
+	stderr := bytes.NewBuffer(nil)
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(stderr))
+	var got []int
+	RegisterTo(c, "run", func(opts *runOptions) error {
+		got = append(got, opts.N)
+		return nil
+	}, "run")
+
+	if err := c.LaunchE("run", "--num", "3"); err != nil {
+		t.Fatal(err)
+	}
+	// a failure of parsing is returned and the handler is not called
+	err := c.LaunchE("run", "--num", "abc")
+	if err == nil || !strings.Contains(err.Error(), `invalid value "abc" for --num`) {
+		t.Errorf("expected the error of parsing, got %v", err)
+	}
+	if len(got) != 1 || got[0] != 3 {
+		t.Errorf("expected the handler called once with 3, got %v", got)
+	}
+	// the next launch is not failed by the previous error
+	if err := c.LaunchE("run"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1] != 1 {
+		t.Errorf("expected the default 1, got %v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package cortana
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// MatchKind describes how the command is matched
+type MatchKind int
+
+const (
+	// MatchNone means no command is matched
+	MatchNone MatchKind = iota
+	// MatchExact means the args name the command exactly
+	MatchExact
+	// MatchPrefix means the args go deeper than the command, along a path which
+	// is a prefix of other commands, and fall back to the command
+	MatchPrefix
+)
+
+func (k MatchKind) String() string {
+	switch k {
+	case MatchExact:
+		return "exact"
+	case MatchPrefix:
+		return "prefix"
+	}
+	return "none"
+}
+
+// Resolution describes which command an invocation would run
+type Resolution struct {
+	Command     *Command // nil if no command is matched
+	Match       MatchKind
+	Flags       []string // the residual args which look like flags
+	Positionals []string // the other residual args
+	RCArgs      []string // the residual args which come from the rc file, they lead the others
+	RCFile      string   // the rc file of the RCArgs, empty if there is none
+	Args        []string // the residual args in order, led by the RCArgs
+}
+
+// Resolve returns the command the args would run, no hook or command is invoked
+// and the state of the commander is untouched
+func (c *Cortana) Resolve(args []string) *Resolution {
+	cmd, ctx := c.searchCommand(args)
+	if cmd != nil && c.rcfile != "" {
+		// an unreadable rc file fails the launch, the resolution goes without it
+		if resolved, rctx, err := c.searchRCFile(cmd, ctx); err == nil {
+			cmd, ctx = resolved, rctx
+		}
+	}
+	r := &Resolution{Command: (*Command)(cmd)}
+	if cmd == nil {
+		return r
+	}
+	r.Args = ctx.args
+	r.RCArgs = ctx.args[:ctx.rcArgs]
+	if len(r.RCArgs) > 0 {
+		r.RCFile = c.rcfile
+	}
+	r.Match = MatchExact
+	if ctx.longest != cmd.Path {
+		r.Match = MatchPrefix
+	}
+	for i, arg := range ctx.args {
+		if arg == "--" {
+			r.Positionals = append(r.Positionals, ctx.args[i+1:]...)
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			r.Flags = append(r.Flags, arg)
+		} else {
+			r.Positionals = append(r.Positionals, arg)
+		}
+	}
+	return r
+}
+
+// ExplainFlag adds a flag to all the commands, like --explain, which prints the
+// command the args resolve to and the args added from the rc file instead of
+// running it. It takes effect in Launch, before the args are parsed
+func ExplainFlag(long, short string) Option {
+	return func(c *Cortana) {
+		c.predefined.explain.long = long
+		c.predefined.explain.short = short
+		c.predefined.explain.desc = "print how the command is resolved and exit"
+	}
+}
+
+// stripExplain removes the explain flag before "--" and reports if it is given
+func (c *Cortana) stripExplain(args []string) ([]string, bool) {
+	explain := c.predefined.explain
+	if explain.long == "" && explain.short == "" {
+		return args, false
+	}
+	var found bool
+	stripped := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			stripped = append(stripped, args[i:]...)
+			break
+		}
+		if arg != "" && (c.foldFlag(arg) == c.foldFlag(explain.long) || arg == explain.short) {
+			found = true
+			continue
+		}
+		stripped = append(stripped, arg)
+	}
+	return stripped, found
+}
+
+// explain renders the resolution, the args from the rc file are told apart from
+// the typed ones
+func (r *Resolution) explain() string {
+	buf := bytes.NewBuffer(nil)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	if r.Command == nil {
+		fmt.Fprintln(w, "command:\tnone")
+		w.Flush()
+		return buf.String()
+	}
+	path := r.Command.Path
+	if path == "" {
+		path = "(root)"
+	}
+	fmt.Fprintf(w, "command:\t%s (%s match)\n", path, r.Match)
+	if len(r.RCArgs) > 0 {
+		fmt.Fprintf(w, "rc args:\t%s (from %s)\n", quoteArgs(r.RCArgs), r.RCFile)
+	}
+	fmt.Fprintf(w, "args:\t%s\n", quoteArgs(r.Args[len(r.RCArgs):]))
+	w.Flush()
+	return buf.String()
+}
+
+// quoteArgs joins the args, the empty ones and the ones with spaces are quoted
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
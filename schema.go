@@ -0,0 +1,208 @@
+package cortana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigSchema generates a JSON Schema (draft-07) of the configuration from the
+// cortana tags of v. The properties are named by the config tag or the long name of
+// the flags, and a dotted name is turned into nested objects
+func ConfigSchema(v interface{}) ([]byte, error) {
+	return configSchema(v, tagOptions{})
+}
+
+func configSchema(v interface{}, opts tagOptions) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("cortana: ConfigSchema requires a struct, got " + rv.Kind().String())
+	}
+	// parse the tags against a fresh instance, so v is never touched
+	flags, _, err := parseTags(reflect.New(rv.Type()), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	root := newObjectSchema()
+	root.fields["$schema"] = "http://json-schema.org/draft-07/schema#"
+	for _, f := range flags {
+		name := f.configKey
+		if name == "" {
+			name = strings.TrimLeft(f.long, "-")
+		}
+		if name == "" {
+			name = strings.ToLower(f.name)
+		}
+
+		parts := strings.Split(name, ".")
+		obj := root
+		for _, part := range parts[:len(parts)-1] {
+			obj = obj.object(part)
+		}
+		prop, err := flagSchema(f)
+		if err != nil {
+			return nil, err
+		}
+		obj.properties[parts[len(parts)-1]] = prop
+		if f.required {
+			obj.required = append(obj.required, parts[len(parts)-1])
+		}
+	}
+	return json.MarshalIndent(root.build(), "", "  ")
+}
+
+// objectSchema builds the schema of a json object
+type objectSchema struct {
+	fields     map[string]interface{}
+	properties map[string]interface{}
+	required   []string
+	children   map[string]*objectSchema
+}
+
+func newObjectSchema() *objectSchema {
+	return &objectSchema{
+		fields:     map[string]interface{}{"type": "object"},
+		properties: make(map[string]interface{}),
+		children:   make(map[string]*objectSchema),
+	}
+}
+
+// object returns the nested object, it is created if not exist
+func (o *objectSchema) object(name string) *objectSchema {
+	child, ok := o.children[name]
+	if !ok {
+		child = newObjectSchema()
+		o.children[name] = child
+	}
+	return child
+}
+
+func (o *objectSchema) build() map[string]interface{} {
+	schema := make(map[string]interface{})
+	for k, v := range o.fields {
+		schema[k] = v
+	}
+	properties := make(map[string]interface{})
+	for k, v := range o.properties {
+		properties[k] = v
+	}
+	for k, child := range o.children {
+		properties[k] = child.build()
+	}
+	schema["properties"] = properties
+	if len(o.required) > 0 {
+		schema["required"] = o.required
+	}
+	return schema
+}
+
+// flagSchema returns the schema of a flag
+func flagSchema(f *flag) (map[string]interface{}, error) {
+	if err := f.parseBounds(); err != nil {
+		return nil, fmt.Errorf("invalid bounds of %s: %v", f.name, err)
+	}
+	schema := typeSchema(f.rv.Type())
+	if f.description != "" {
+		schema["description"] = f.description
+	}
+	// the choices and the bounds restrict every element of a list
+	constrained, rt := schema, f.rv.Type()
+	if items, ok := schema["items"].(map[string]interface{}); ok && schema["type"] == "array" {
+		constrained, rt = items, rt.Elem()
+	}
+	if len(f.choices) > 0 {
+		constrained["enum"] = enumValues(f.choices, rt, constrained["type"])
+	}
+	// the bounds of a duration are strings which the schema can not compare
+	if f.minValue.IsValid() && f.minValue.Type() != reflect.TypeOf(time.Duration(0)) {
+		constrained["minimum"] = f.minValue.Interface()
+	}
+	if f.maxValue.IsValid() && f.maxValue.Type() != reflect.TypeOf(time.Duration(0)) {
+		constrained["maximum"] = f.maxValue.Interface()
+	}
+	if !f.required && f.defaultValue != "" && f.defaultFunc == nil && !(f.rv.Kind() == reflect.Slice && f.defaultValue == "nil") {
+		v := reflect.New(f.rv.Type()).Elem()
+		if err := applyDefault(f, v); err != nil {
+			return nil, fmt.Errorf("invalid default value of %s: %v", f.name, err)
+		}
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			schema["default"] = f.defaultValue
+		} else {
+			schema["default"] = v.Interface()
+		}
+	}
+	return schema, nil
+}
+
+// enumValues returns the choices typed as the json values of the type, like 1 for
+// an int, so they compare with the values in the configuration
+func enumValues(choices []string, rt reflect.Type, typ interface{}) []interface{} {
+	values := make([]interface{}, 0, len(choices))
+	for _, choice := range choices {
+		var value interface{} = choice
+		if typ == "integer" || typ == "number" || typ == "boolean" {
+			v := reflect.New(rt)
+			if err := json.Unmarshal([]byte(choice), v.Interface()); err == nil {
+				value = v.Elem().Interface()
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// typeSchema returns the schema of a go type
+func typeSchema(rt reflect.Type) map[string]interface{} {
+	if rt == reflect.TypeOf(time.Duration(0)) || isText(rt) {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch rt.Kind() {
+	case reflect.Ptr:
+		return typeSchema(rt.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(rt.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(rt.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < rt.NumField(); i++ {
+			if ft := rt.Field(i); ft.PkgPath == "" {
+				properties[ft.Name] = typeSchema(ft.Type)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
+
+// SchemaCommand adds a hidden command "config schema" which prints the JSON Schema
+// of the configuration described by v
+func SchemaCommand(v interface{}) Option {
+	return func(c *Cortana) {
+		c.AddCommand("config schema", func() {
+			data, err := configSchema(v, c.tags)
+			if err != nil {
+				c.fatal(err)
+				return
+			}
+			fmt.Fprintln(c.stdout, string(data))
+		}, "print the JSON Schema of the configuration", Hidden())
+	}
+}
@@ -0,0 +1,55 @@
+package cortana
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestConfigSchemaConstraints(t *testing.T) {
+	type options struct {
+		Level   string   `cortana:"--level, -l, info, log level" choices:"debug,info"`
+		Workers int      `cortana:"--workers, -w, 4, workers" min:"1" max:"64"`
+		Retries uint8    `cortana:"--retries, -, 3, retries" choices:"1,3,5"`
+		Ratio   float64  `cortana:"--ratio, -, 0.5, ratio" min:"0" max:"1"`
+		Zones   []string `cortana:"--zone, -z, , zones" choices:"a,b"`
+		Ports   []int    `cortana:"--port, -p, , ports" min:"1" max:"65535"`
+	}
+	data, err := ConfigSchema(&options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		property string
+		path     []string
+		want     interface{}
+	}{
+		{"level", []string{"enum"}, []interface{}{"debug", "info"}},
+		{"workers", []string{"minimum"}, float64(1)},
+		{"workers", []string{"maximum"}, float64(64)},
+		{"retries", []string{"enum"}, []interface{}{float64(1), float64(3), float64(5)}},
+		{"ratio", []string{"maximum"}, float64(1)},
+		{"zone", []string{"items", "enum"}, []interface{}{"a", "b"}},
+		{"port", []string{"items", "minimum"}, float64(1)},
+		{"port", []string{"items", "maximum"}, float64(65535)},
+	}
+	for _, tc := range cases {
+		var got interface{} = schema.Properties[tc.property]
+		for _, key := range tc.path {
+			m, _ := got.(map[string]interface{})
+			got = m[key]
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %v: expected %v, got %v", tc.property, tc.path, tc.want, got)
+		}
+	}
+	if _, ok := schema.Properties["zone"]["enum"]; ok {
+		t.Error("expected the enum on the items of a list")
+	}
+}
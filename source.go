@@ -0,0 +1,206 @@
+package cortana
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"text/tabwriter"
+)
+
+// The kinds of the source of a value
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceEnv     = "env"
+	SourceArg     = "arg"
+	SourceRCFile  = "rcfile"
+)
+
+// Source describes where the effective value of a flag comes from
+type Source struct {
+	Kind   string // SourceDefault, SourceConfig, SourceEnv, SourceRCFile or SourceArg, empty if not set
+	Detail string // the path of the config or rc file, the name of the env or the flag as typed
+}
+
+// isArgs reports if the value is given in the args, typed or from the rc file
+func (s Source) isArgs() bool {
+	return s.Kind == SourceArg || s.Kind == SourceRCFile
+}
+
+// snapshot copies the values of all the flags being parsed
+func (c *Cortana) snapshot() []reflect.Value {
+	var values []reflect.Value
+	for _, f := range c.parsingFlags() {
+		values = append(values, cloneValue(f.rv))
+	}
+	return values
+}
+
+// passState is the state of the flags before the sources are applied, a pass of
+// the parse restarted by --config or --profile starts over from it
+type passState struct {
+	values  []reflect.Value
+	sources []Source
+}
+
+// savePass saves the state of the flags before the first pass
+func (c *Cortana) savePass() passState {
+	state := passState{values: c.snapshot()}
+	for _, f := range c.parsingFlags() {
+		state.sources = append(state.sources, f.source)
+	}
+	return state
+}
+
+// resetPass restores the flags which the args accumulate into, so the args applied
+// by a pass before the restart are not applied twice
+func (c *Cortana) resetPass(state passState) {
+	for i, f := range c.parsingFlags() {
+		if i >= len(state.values) || !state.values[i].IsValid() {
+			continue
+		}
+		if f.count || f.hasJoin || f.rv.Kind() == reflect.Slice || f.rv.Kind() == reflect.Array {
+			f.rv.Set(cloneValue(state.values[i]))
+			f.source = state.sources[i]
+			f.filled = 0
+		}
+	}
+}
+
+// recordChanges sets the source of the flags changed since the snapshot and
+// returns the changed flags
+func (c *Cortana) recordChanges(before []reflect.Value, source Source) []*flag {
+	var changed []*flag
+	for i, f := range c.parsingFlags() {
+		if i >= len(before) || !before[i].IsValid() || !f.rv.CanInterface() {
+			continue
+		}
+		if !reflect.DeepEqual(before[i].Interface(), f.rv.Interface()) {
+			f.source = source
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+// parsingFlags returns both the flags and nonflags being parsed
+func (c *Cortana) parsingFlags() []*flag {
+	flags := make([]*flag, 0, len(c.parsing.flags)+len(c.parsing.nonflags))
+	flags = append(flags, c.parsing.flags...)
+	for _, nf := range c.parsing.nonflags {
+		flags = append(flags, (*flag)(nf))
+	}
+	return flags
+}
+
+// cloneValue deeply copies the slices, maps and pointers of the value, so the
+// changes made in place can be detected
+func cloneValue(rv reflect.Value) reflect.Value {
+	if !rv.IsValid() || !rv.CanInterface() {
+		return reflect.Value{}
+	}
+	cloned := reflect.New(rv.Type()).Elem()
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return cloned
+		}
+		cloned.Set(reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len()))
+		for i := 0; i < rv.Len(); i++ {
+			cloned.Index(i).Set(cloneValue(rv.Index(i)))
+		}
+	case reflect.Map:
+		if rv.IsNil() {
+			return cloned
+		}
+		cloned.Set(reflect.MakeMapWithSize(rv.Type(), rv.Len()))
+		iter := rv.MapRange()
+		for iter.Next() {
+			cloned.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return cloned
+		}
+		cloned.Set(reflect.New(rv.Type().Elem()))
+		cloned.Elem().Set(cloneValue(rv.Elem()))
+	default:
+		cloned.Set(rv)
+	}
+	return cloned
+}
+
+// the text reported instead of the value of a secret flag
+const maskedValue = "******"
+
+// valueText renders the value of the flag, the value of a secret is masked
+func (f *flag) valueText() string {
+	if !f.rv.IsValid() || !f.rv.CanInterface() {
+		return ""
+	}
+	if f.secret {
+		return maskedValue
+	}
+	return fmt.Sprint(f.rv.Interface())
+}
+
+// Flags returns the flags of the last parsing with the sources of their values,
+// the values of the secret flags are masked
+func (c *Cortana) Flags() []FlagInfo {
+	var infos []FlagInfo
+	for _, f := range c.parsing.flags {
+		info := f.info()
+		info.Value = f.valueText()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// WasSet reports if the flag named by its long, short or alias name is set by a
+// config, an env or the args in the last parsing, the default values are not counted
+func (c *Cortana) WasSet(name string) bool {
+	name = c.foldFlag(name)
+	for _, f := range c.parsing.flags {
+		names := append([]string{c.foldFlag(f.long), f.short}, f.aliases...)
+		for _, n := range names {
+			if n != "" && n != "-" && c.foldFlag(n) == name {
+				return f.isSet()
+			}
+		}
+	}
+	return false
+}
+
+// DumpConfigFlag adds a flag to all the commands, like --dump-config, which prints
+// the effective value of every flag with where it comes from and exits as the help
+// flag does. The values of the secret flags are masked, only their sources are shown
+func DumpConfigFlag(long, short string) Option {
+	return func(c *Cortana) {
+		c.predefined.dump.long = long
+		c.predefined.dump.short = short
+		c.predefined.dump.desc = "print the effective configuration and exit"
+	}
+}
+
+// dumpConfig renders the values and sources of the flags being parsed
+func (c *Cortana) dumpConfig() string {
+	buf := bytes.NewBuffer(nil)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FLAG\tVALUE\tSOURCE")
+	for _, f := range c.parsingFlags() {
+		name := f.displayName()
+		if name == "" || name == "-" {
+			name = f.name
+		}
+		source := f.source.Kind
+		if source == "" {
+			source = "unset"
+		}
+		if f.source.Detail != "" {
+			source += " (" + f.source.Detail + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, f.valueText(), source)
+	}
+	w.Flush()
+	return buf.String()
+}
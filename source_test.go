@@ -0,0 +1,122 @@
+package cortana
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// prefixEnvs sets the fields from the envs in the map, named by the prefix
+type prefixEnvs struct {
+	prefix string
+	envs   map[string]string
+}
+
+func (p prefixEnvs) Unmarshal(v interface{}) error {
+	if token, ok := p.envs[p.EnvName("Token")]; ok {
+		v.(*sourceOptions).Token = token
+	}
+	return nil
+}
+
+func (p prefixEnvs) EnvName(field string) string {
+	return p.prefix + strings.ToUpper(field)
+}
+
+type sourceOptions struct {
+	Host  string `cortana:"--host, -h, localhost, host"`
+	Port  int    `cortana:"--port, -p, 80, port"`
+	Token string `cortana:"--token, -t, , token" secret:"true"`
+	Debug bool   `cortana:"--debug, -d, false, debug"`
+}
+
+// newSourceCortana returns a cortana with a config setting Port and an env setting Token
+func newSourceCortana(t *testing.T, opts ...Option) (*Cortana, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "c.json")
+	if err := ioutil.WriteFile(path, []byte(`{"Port": 8080}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := New(append([]Option{ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard)}, opts...)...)
+	c.AddConfig(path, UnmarshalFunc(json.Unmarshal))
+	c.AddEnvUnmarshaler(prefixEnvs{prefix: "APP_", envs: map[string]string{"APP_TOKEN": "s3cr3t"}})
+	return c, path
+}
+
+func TestFlagSources(t *testing.T) {
+	c, path := newSourceCortana(t)
+	var opts sourceOptions
+	c.Parse(&opts, WithArgs([]string{"--debug"}))
+	if opts.Token != "s3cr3t" || opts.Port != 8080 {
+		t.Fatalf("unexpected values %+v", opts)
+	}
+
+	want := map[string]struct {
+		source Source
+		value  string
+	}{
+		"--host":  {Source{Kind: SourceDefault}, "localhost"},
+		"--port":  {Source{Kind: SourceConfig, Detail: path}, "8080"},
+		"--token": {Source{Kind: SourceEnv, Detail: "APP_TOKEN"}, maskedValue},
+		"--debug": {Source{Kind: SourceArg, Detail: "--debug"}, "true"},
+	}
+	for _, f := range c.Flags() {
+		w := want[f.Long]
+		if f.Source != w.source || f.Value != w.value {
+			t.Errorf("%s: expected %+v %q, got %+v %q", f.Long, w.source, w.value, f.Source, f.Value)
+		}
+		if f.Secret != (f.Long == "--token") {
+			t.Errorf("%s: unexpected Secret %v", f.Long, f.Secret)
+		}
+	}
+
+	for name, set := range map[string]bool{"--host": false, "-p": true, "--token": true, "-d": true, "--nope": false} {
+		if c.WasSet(name) != set {
+			t.Errorf("WasSet(%q): expected %v", name, set)
+		}
+	}
+}
+
+func TestDumpConfigFlag(t *testing.T) {
+	c, path := newSourceCortana(t, DumpConfigFlag("--dump-config", ""))
+	var opts sourceOptions
+	var dump string
+	c.Parse(&opts, WithArgs([]string{"--dump-config", "--host", "example.com"}), OnUsage(func(s string) {
+		dump = s
+	}))
+	for _, line := range []string{
+		"--host   example.com  arg (--host)",
+		"--port   8080         config (" + path + ")",
+		"--token  ******       env (APP_TOKEN)",
+		"--debug  false        default",
+	} {
+		if !strings.Contains(dump, line) {
+			t.Errorf("expected %q in the dump:\n%s", line, dump)
+		}
+	}
+	if strings.Contains(dump, "s3cr3t") {
+		t.Errorf("the secret is dumped:\n%s", dump)
+	}
+}
+
+func TestRecordMasksSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.json")
+	t.Setenv("CORTANA_TEST_RECORD", path)
+	c, _ := newSourceCortana(t, RecordEnv("CORTANA_TEST_RECORD"))
+	var opts sourceOptions
+	c.AddCommand("run", func() { c.Parse(&opts) }, "run")
+	if err := c.LaunchE("run"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("s3cr3t")) || !bytes.Contains(data, []byte(maskedValue)) {
+		t.Errorf("the secret is not masked in the record:\n%s", data)
+	}
+}
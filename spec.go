@@ -0,0 +1,250 @@
+package cortana
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Spec describes the commands registered at runtime by LoadSpec
+type Spec struct {
+	Commands []CommandSpec `json:"commands"`
+}
+
+// CommandSpec describes a command of the spec
+type CommandSpec struct {
+	Path   string     `json:"path"`
+	Brief  string     `json:"brief"`
+	Hidden bool       `json:"hidden,omitempty"`
+	Args   string     `json:"args,omitempty"` // the name of the positional args in the usage
+	Flags  []FlagSpec `json:"flags,omitempty"`
+}
+
+// FlagSpec describes a flag of a command in the spec
+type FlagSpec struct {
+	Long        string   `json:"long"`
+	Short       string   `json:"short,omitempty"`
+	Type        string   `json:"type,omitempty"` // string, bool, int, float, duration or strings, string by default
+	Default     string   `json:"default,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+	Hidden      bool     `json:"hidden,omitempty"`     // the flag is parsed but not shown in the usage
+	Deprecated  string   `json:"deprecated,omitempty"` // the message warned if the flag is used
+}
+
+// DynamicFlags are the values of the flags of a command loaded from the spec, the keys
+// are the long names without the dashes and the values are typed as the spec says
+type DynamicFlags map[string]interface{}
+
+// the go types of the flags in the spec
+var specTypes = map[string]reflect.Type{
+	"":         reflect.TypeOf(""),
+	"string":   reflect.TypeOf(""),
+	"bool":     reflect.TypeOf(false),
+	"int":      reflect.TypeOf(0),
+	"float":    reflect.TypeOf(float64(0)),
+	"duration": reflect.TypeOf(time.Duration(0)),
+	"strings":  reflect.TypeOf([]string{}),
+}
+
+// LoadSpec adds the commands described by the json spec, the handler runs them
+// with the parsed flags and the positional args. An invalid entry of the spec is
+// reported with its position and no command is added
+func (c *Cortana) LoadSpec(data []byte, handler func(path string, flags DynamicFlags, args []string) error) error {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("cortana: invalid spec: %v", err)
+	}
+
+	types := make([]reflect.Type, len(spec.Commands))
+	paths := make(map[string]int)
+	for i, cmd := range spec.Commands {
+		path := strings.Join(strings.Fields(cmd.Path), " ")
+		if path == "" {
+			return fmt.Errorf("cortana: spec commands[%d]: the path is empty", i)
+		}
+		if j, ok := paths[path]; ok {
+			return fmt.Errorf("cortana: spec commands[%d]: the path %q is duplicated with commands[%d]", i, path, j)
+		}
+		paths[path] = i
+		rt, err := specStruct(cmd)
+		if err != nil {
+			return fmt.Errorf("cortana: spec commands[%d] (%s): %v", i, path, err)
+		}
+		types[i] = rt
+	}
+
+	for i, cmd := range spec.Commands {
+		cmd, rt := cmd, types[i]
+		path := strings.Join(strings.Fields(cmd.Path), " ")
+		proc := func() {
+			rv := reflect.New(rt)
+			c.Parse(rv.Interface())
+			flags, err := c.dynamicFlags(cmd, rv.Elem())
+			if err != nil {
+				c.fatal(err)
+				return
+			}
+			args := rv.Elem().Field(len(cmd.Flags)).Interface().([]string)
+			if err := handler(path, flags, args); err != nil {
+				c.fatal(err)
+			}
+		}
+		loaded := cmd
+		loaded.Path = path
+		opts := []CommandOption{WithFlags(reflect.New(rt).Interface()), func(c *Command) { c.spec = &loaded }}
+		if cmd.Hidden {
+			opts = append(opts, Hidden())
+		}
+		c.AddCommand(path, proc, cmd.Brief, opts...)
+	}
+	return nil
+}
+
+// specStruct builds the options struct of the command, a field for each of the flags
+// and the last one for the positional args
+func specStruct(cmd CommandSpec) (reflect.Type, error) {
+	var fields []reflect.StructField
+	for j, fs := range cmd.Flags {
+		rt, ok := specTypes[fs.Type]
+		if !ok {
+			return nil, fmt.Errorf("flags[%d] (%s): unknown type %q", j, fs.Long, fs.Type)
+		}
+		short := fs.Short
+		if short == "" {
+			short = "-"
+		}
+		// the commas of the default value are escaped to be kept in the tag
+		defaultValue := strings.ReplaceAll(fs.Default, ",", `\,`)
+		if fs.Required {
+			defaultValue = "-"
+		}
+		description := fs.Description
+		if fs.Deprecated != "" {
+			description += " (deprecated: " + fs.Deprecated + ")"
+		}
+		tag := strings.Join([]string{fs.Long, short, defaultValue, strings.TrimSpace(description)}, ", ")
+		stag := fmt.Sprintf("cortana:%q choices:%q", tag, strings.Join(fs.Choices, ","))
+		if fs.Hidden {
+			stag += ` hidden:"true"`
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Flag%d", j),
+			Type: rt,
+			Tag:  reflect.StructTag(stag),
+		})
+	}
+	args := cmd.Args
+	if args == "" {
+		args = "args"
+	}
+	fields = append(fields, reflect.StructField{
+		Name: "Args",
+		Type: reflect.TypeOf([]string{}),
+		Tag:  reflect.StructTag(fmt.Sprintf("cortana:%q", args+", -, , ")),
+	})
+	rt := reflect.StructOf(fields)
+
+	flags, _, err := parseTags(reflect.New(rt), tagOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for j, f := range flags {
+		f.path = fmt.Sprintf("flags[%d]", j)
+		if err := validateFlag(f); err != nil {
+			return nil, errors.New(strings.TrimPrefix(err.Error(), "cortana: field "))
+		}
+		if !strings.HasPrefix(f.long, "--") {
+			return nil, fmt.Errorf("flags[%d]: the long name %q should start with --", j, f.long)
+		}
+		if fs := cmd.Flags[j]; len(fs.Choices) > 0 && fs.Default != "" && !containsString(fs.Choices, fs.Default) {
+			return nil, fmt.Errorf("flags[%d] (%s): the default value %q is not one of the choices", j, f.long, fs.Default)
+		}
+	}
+	return rt, nil
+}
+
+// dynamicFlags collects the values of the flags, the deprecated flags are warned
+// if they are given
+func (c *Cortana) dynamicFlags(cmd CommandSpec, rv reflect.Value) (DynamicFlags, error) {
+	flags := make(DynamicFlags)
+	for j, fs := range cmd.Flags {
+		fv := rv.Field(j)
+		f := c.findFlag(fs.Long)
+		if fs.Deprecated != "" && f != nil && f.isSet() {
+			fmt.Fprintf(c.stderr, "warning: %s is deprecated: %s\n", fs.Long, fs.Deprecated)
+		}
+		flags[strings.TrimLeft(fs.Long, "-")] = fv.Interface()
+	}
+	return flags, nil
+}
+
+// Spec exports the commands in the format of LoadSpec, so a spec can be generated
+// from the compiled commands or saved after loading. A command loaded from a spec
+// is exported as it is loaded, the flags of the other commands are known only if
+// they are added with the WithFlags option. The root and the alias commands and the
+// flags without a long name are not exported
+func (c *Cortana) Spec() Spec {
+	var spec Spec
+	cmds := c.commands.scan("")
+	sort.Sort(orderedCommands(cmds))
+	for _, cmd := range cmds {
+		if cmd.Path == "" || cmd.Alias {
+			continue
+		}
+		if cmd.spec != nil {
+			spec.Commands = append(spec.Commands, *cmd.spec)
+			continue
+		}
+		cs := CommandSpec{Path: cmd.Path, Brief: cmd.Brief, Hidden: cmd.hidden}
+		if cmd.options != nil {
+			flags, nonflags := parseCortanaTags(reflect.New(cmd.options), c.tags)
+			for _, v := range c.persistent {
+				persistent, _ := parseCortanaTags(reflect.New(reflect.TypeOf(v).Elem()), c.tags)
+				flags = append(flags, persistent...)
+			}
+			for _, f := range flags {
+				if strings.HasPrefix(f.long, "--") {
+					cs.Flags = append(cs.Flags, flagSpec(f))
+				}
+			}
+			if len(nonflags) > 0 {
+				cs.Args = nonflags[0].info().Placeholder
+			}
+		}
+		spec.Commands = append(spec.Commands, cs)
+	}
+	return spec
+}
+
+// flagSpec describes the flag in the spec, the types unknown to the spec are
+// strings
+func flagSpec(f *flag) FlagSpec {
+	fs := FlagSpec{
+		Long:        f.long,
+		Description: f.description,
+		Required:    f.required,
+		Choices:     f.choices,
+		Hidden:      f.hidden,
+	}
+	if f.short != "-" {
+		fs.Short = f.short
+	}
+	if !f.required {
+		fs.Default = f.defaultValue
+	}
+	for name, rt := range specTypes {
+		if name != "" && f.rv.Type() == rt {
+			fs.Type = name
+		}
+	}
+	if fs.Type == "string" {
+		fs.Type = ""
+	}
+	return fs
+}
@@ -0,0 +1,87 @@
+package cortana
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSpecRoundTrip(t *testing.T) {
+	spec := Spec{Commands: []CommandSpec{
+		{Path: "users list", Brief: "list the users", Args: "ids", Flags: []FlagSpec{
+			{Long: "--limit", Short: "-l", Type: "int", Default: "10", Description: "the max number"},
+			{Long: "--format", Default: "table", Description: "output format", Choices: []string{"table", "json"}},
+			{Long: "--debug-api", Type: "bool", Default: "false", Description: "dump the requests", Hidden: true},
+			{Long: "--page-size", Type: "int", Default: "0", Description: "page size", Deprecated: "use --limit"},
+		}},
+		{Path: "users  delete", Brief: "delete a user", Hidden: true, Flags: []FlagSpec{
+			{Long: "--id", Description: "the user id", Required: true},
+		}},
+	}}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(path string, flags DynamicFlags, args []string) error { return nil }
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	if err := c.LoadSpec(data, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	// the loaded commands are exported as they are loaded, with the paths normalized
+	want := spec
+	want.Commands = append([]CommandSpec{}, spec.Commands...)
+	want.Commands[1].Path = "users delete"
+	got := c.Spec()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	// the exported spec loads again into the same spec
+	data, err = json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	if err := c2.LoadSpec(data, handler); err != nil {
+		t.Fatal(err)
+	}
+	if again := c2.Spec(); !reflect.DeepEqual(again, want) {
+		t.Errorf("expected %+v, got %+v", want, again)
+	}
+}
+
+func TestSpecOfCompiledCommands(t *testing.T) {
+	type options struct {
+		Timeout time.Duration `cortana:"--timeout, -t, 5s, the timeout"`
+		Level   string        `cortana:"--level, -, info, log level" choices:"debug,info"`
+		Token   string        `cortana:"--token, -, -, the token" hidden:"true"`
+		Files   []string      `cortana:"files, -, , the files"`
+	}
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	c.AddCommand("upload", func() {}, "upload the files", WithFlags(&options{}))
+	c.AddCommand("version", func() {}, "print the version", Hidden())
+	c.Alias("up", "upload")
+
+	want := Spec{Commands: []CommandSpec{
+		{Path: "upload", Brief: "upload the files", Args: "files", Flags: []FlagSpec{
+			{Long: "--timeout", Short: "-t", Type: "duration", Default: "5s", Description: "the timeout"},
+			{Long: "--level", Default: "info", Description: "log level", Choices: []string{"debug", "info"}},
+			{Long: "--token", Description: "the token", Required: true, Hidden: true},
+		}},
+		{Path: "version", Brief: "print the version", Hidden: true},
+	}}
+	got := c.Spec()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New().LoadSpec(data, func(string, DynamicFlags, []string) error { return nil }); err != nil {
+		t.Errorf("the exported spec is not loaded: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package cortana
+
+import "io/ioutil"
+
+// StagedParse parses the args into several structs at once, the flags of all the
+// structs are accepted and listed in one usage
+type StagedParse struct {
+	c      *Cortana
+	values []interface{}
+}
+
+// BeginParse starts a staged parse, bind the structs then finish it
+//
+//	p := c.BeginParse()
+//	p.Bind(&common)
+//	if common.Driver == "docker" {
+//	    p.Bind(&docker)
+//	}
+//	p.Finish()
+func (c *Cortana) BeginParse() *StagedParse {
+	return &StagedParse{c: c}
+}
+
+// Bind adds the struct to the parse. Its values are previewed from the sources right
+// away, so it can decide which struct to bind next. The unknown args are ignored and
+// the errors are not reported until Finish
+func (p *StagedParse) Bind(v interface{}) {
+	p.values = append(p.values, v)
+
+	c := p.c
+	args, configs := c.ctx.args, c.configs
+	stderr, exitOnErr := c.stderr, c.exitOnErr
+	c.stderr, c.exitOnErr = ioutil.Discard, false
+	defer func() {
+		c.ctx.args, c.configs = args, configs
+		c.stderr, c.exitOnErr = stderr, exitOnErr
+	}()
+	c.parse([]interface{}{v}, IgnoreUnknownArgs(), func(opt *parseOption) {
+		opt.preview = true
+	})
+}
+
+// Finish parses the args into all the bound structs, a flag bound by more than one
+// struct is an error
+func (p *StagedParse) Finish(opts ...ParseOption) {
+	p.c.parse(p.values, opts...)
+}
@@ -0,0 +1,75 @@
+package cortana
+
+import (
+	"sort"
+	"strings"
+)
+
+// Suggest returns at most 3 candidates which are similar to name by the edit
+// distance or prefixed by it, the closest first. It is how the unknown commands are
+// suggested, and is handy for the completion tools to do the same
+func Suggest(name string, candidates []string) []string {
+	type scored struct {
+		s string
+		d int
+	}
+	// the threshold grows with the length of the name, so longer names tolerate more typos
+	threshold := len(name)/3 + 1
+	if threshold > 3 {
+		threshold = 3
+	}
+
+	var matched []scored
+	seen := make(map[string]struct{})
+	for _, cand := range candidates {
+		if _, ok := seen[cand]; ok || cand == "" {
+			continue
+		}
+		seen[cand] = struct{}{}
+		d := levenshtein(name, cand)
+		if d <= threshold || strings.HasPrefix(cand, name) {
+			matched = append(matched, scored{s: cand, d: d})
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].d < matched[j].d
+	})
+
+	var suggestions []string
+	for i := 0; i < len(matched) && i < 3; i++ {
+		suggestions = append(suggestions, matched[i].s)
+	}
+	return suggestions
+}
+
+// levenshtein computes the edit distance of a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
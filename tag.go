@@ -0,0 +1,41 @@
+package cortana
+
+import "reflect"
+
+// tagOptions controls how the cortana tags are parsed
+type tagOptions struct {
+	name             string // the custom tag name looked up before "cortana"
+	dotted           bool   // name the flags of nested structs with the dotted field path
+	extendedDuration bool   // accept days and weeks for all the duration flags
+	strict           bool   // the tags of the unexported fields are errors
+}
+
+// tag returns the cortana tag of a field
+func (opts tagOptions) tag(tag reflect.StructTag) string {
+	if opts.name != "" {
+		if v := tag.Get(opts.name); v != "" {
+			return v
+		}
+	}
+	if v := tag.Get("cortana"); v != "" {
+		return v
+	}
+	return tag.Get("lsdd") // lsdd is short for (long short default description)
+}
+
+// lookup returns the tag modifier like env, the one prefixed by the custom tag name
+// like "cli-env" takes precedence
+func (opts tagOptions) lookup(tag reflect.StructTag, key string) (string, bool) {
+	if opts.name != "" {
+		if v, ok := tag.Lookup(opts.name + "-" + key); ok {
+			return v, true
+		}
+	}
+	return tag.Lookup(key)
+}
+
+// get returns the tag modifier, it is empty if not found
+func (opts tagOptions) get(tag reflect.StructTag, key string) string {
+	v, _ := opts.lookup(tag, key)
+	return v
+}
@@ -0,0 +1,72 @@
+package cortana
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// transforms normalize the string values, they are composed by the transform tag
+// like transform:"trim,lower" and applied in the listed order
+var transforms = map[string]func(s string) (string, error){
+	"trim": func(s string) (string, error) {
+		return strings.TrimSpace(s), nil
+	},
+	"lower": func(s string) (string, error) {
+		return strings.ToLower(s), nil
+	},
+	"upper": func(s string) (string, error) {
+		return strings.ToUpper(s), nil
+	},
+	"expanduser": normalizePath,
+	"abspath": func(s string) (string, error) {
+		if s == "" {
+			return s, nil
+		}
+		return filepath.Abs(s)
+	},
+}
+
+// checkTransforms reports the unknown transforms of the flags
+func (c *Cortana) checkTransforms() error {
+	for _, f := range c.parsingFlags() {
+		for _, name := range f.transforms {
+			if _, ok := transforms[name]; !ok {
+				return fmt.Errorf("cortana: field %s: unknown transform %q", f.path, name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyTransforms transforms the final values of the string fields and the
+// elements of the string slices
+func (c *Cortana) applyTransforms() error {
+	for _, f := range c.parsingFlags() {
+		if len(f.transforms) == 0 {
+			continue
+		}
+		values := []reflect.Value{f.rv}
+		if f.rv.Kind() == reflect.Slice {
+			values = values[:0]
+			for i := 0; i < f.rv.Len(); i++ {
+				values = append(values, f.rv.Index(i))
+			}
+		}
+		for _, v := range values {
+			if v.Kind() != reflect.String || !v.CanSet() {
+				continue
+			}
+			s := v.String()
+			for _, name := range f.transforms {
+				var err error
+				if s, err = transforms[name](s); err != nil {
+					return fmt.Errorf("%s: %s: %v", f.displayName(), name, err)
+				}
+			}
+			v.SetString(s)
+		}
+	}
+	return nil
+}
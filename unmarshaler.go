@@ -1,5 +1,7 @@
 package cortana
 
+import stdctx "context"
+
 // Unmarshaler unmarshals data to v
 type Unmarshaler interface {
 	Unmarshal(data []byte, v interface{}) error
@@ -13,6 +15,35 @@ func (f UnmarshalFunc) Unmarshal(data []byte, v interface{}) error {
 	return f(data, v)
 }
 
+// ContextUnmarshaler is an Unmarshaler which receives the context of ParseContext,
+// like the one resolving the secrets remotely, it should return once the ctx is done
+type ContextUnmarshaler interface {
+	Unmarshaler
+	UnmarshalContext(ctx stdctx.Context, data []byte, v interface{}) error
+}
+
+// UnmarshalContextFunc turns a func to ContextUnmarshaler, it receives
+// context.Background() if it is called as an Unmarshaler
+type UnmarshalContextFunc func(ctx stdctx.Context, data []byte, v interface{}) error
+
+// Unmarshal the data with context.Background()
+func (f UnmarshalContextFunc) Unmarshal(data []byte, v interface{}) error {
+	return f(stdctx.Background(), data, v)
+}
+
+// UnmarshalContext unmarshals the data
+func (f UnmarshalContextFunc) UnmarshalContext(ctx stdctx.Context, data []byte, v interface{}) error {
+	return f(ctx, data, v)
+}
+
+// unmarshalContext unmarshals the data with the ctx if the unmarshaler takes it
+func unmarshalContext(ctx stdctx.Context, u Unmarshaler, data []byte, v interface{}) error {
+	if cu, ok := u.(ContextUnmarshaler); ok {
+		return cu.UnmarshalContext(ctx, data, v)
+	}
+	return u.Unmarshal(data, v)
+}
+
 // EnvUnmarshaler unmarshals the environment variables
 type EnvUnmarshaler interface {
 	Unmarshal(v interface{}) error
@@ -25,3 +56,31 @@ type EnvUnmarshalFunc func(v interface{}) error
 func (f EnvUnmarshalFunc) Unmarshal(v interface{}) error {
 	return f(v)
 }
+
+// EnvNamer is implemented by an EnvUnmarshaler which can tell the env variable
+// it reads for a field, like APP_SERVER_PORT for Server.Port. The name is recorded
+// in the Source of the flags set by the unmarshaler
+type EnvNamer interface {
+	EnvName(field string) string
+}
+
+// ContextEnvUnmarshaler is an EnvUnmarshaler which receives the context of
+// ParseContext, it should return once the ctx is done
+type ContextEnvUnmarshaler interface {
+	EnvUnmarshaler
+	UnmarshalContext(ctx stdctx.Context, v interface{}) error
+}
+
+// EnvUnmarshalContextFunc turns a func to a ContextEnvUnmarshaler, it receives
+// context.Background() if it is called as an EnvUnmarshaler
+type EnvUnmarshalContextFunc func(ctx stdctx.Context, v interface{}) error
+
+// Unmarshal the environment variables with context.Background()
+func (f EnvUnmarshalContextFunc) Unmarshal(v interface{}) error {
+	return f(stdctx.Background(), v)
+}
+
+// UnmarshalContext unmarshals the environment variables
+func (f EnvUnmarshalContextFunc) UnmarshalContext(ctx stdctx.Context, v interface{}) error {
+	return f(ctx, v)
+}
@@ -0,0 +1,365 @@
+package cortana
+
+import (
+	"bytes"
+	stdctx "context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// UsageModel is what the usage is rendered from, it is handy to render the usage
+// in another way, like a TUI
+type UsageModel struct {
+	Name        string
+	Synopsis    string // the first line of the usage, empty if the flags are unknown
+	Title       string
+	Description string
+	Commands    []CommandInfo // the sub commands in the order of adding
+	Flags       []FlagInfo    // the flags of the struct, then the predefined ones like --help
+	Args        []ArgInfo
+	Examples    []string // the example invocations
+	Footer      string   // the text at the end of the usage
+}
+
+// CommandInfo describes a command in the usage
+type CommandInfo struct {
+	Path   string
+	Brief  string
+	Alias  bool
+	Hidden bool   // hidden commands are not rendered in the usage
+	Group  string // the section of the command, the commands without one come first
+
+	Aliases []string // the other names of the command, like "rm" for "remove"
+}
+
+// UsageModel returns the usage model of the current command
+func (c *Cortana) UsageModel() UsageModel {
+	return c.usageModel(&c.ctx)
+}
+
+// UsageModelOf returns the usage model of the command without executing it, the
+// flags are known only if the command is added with the WithFlags option
+func (c *Cortana) UsageModelOf(path string) (UsageModel, error) {
+	path = c.canonicalPath(path)
+	cmd := c.commands.get(path)
+	if cmd == nil && (path == "" || len(c.commands.children(path)) == 0) {
+		parent, name := "", path
+		if i := strings.LastIndex(path, " "); i >= 0 {
+			parent, name = path[:i], path[i+1:]
+		}
+		return UsageModel{}, c.unknownCommand(parent, name)
+	}
+	return c.usageModel(c.contextOf(path)), nil
+}
+
+// CommandFlags returns the flags of the command without executing it, which is
+// handy for the completion tools. The flags are known only if the command is added
+// with the WithFlags option
+func (c *Cortana) CommandFlags(path string) ([]FlagInfo, error) {
+	m, err := c.UsageModelOf(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.Flags, nil
+}
+
+// usageModel collects the usage model of the context
+func (c *Cortana) usageModel(ctx *context) UsageModel {
+	m := UsageModel{
+		Name:        ctx.name,
+		Title:       ctx.desc.title,
+		Description: ctx.desc.description,
+	}
+
+	commands := c.commands.scan(ctx.longest)
+	// ignore the command itself
+	if len(commands) > 0 && commands[0].Path == ctx.name {
+		commands = commands[1:]
+	}
+	sort.Sort(orderedCommands(commands))
+	for _, cmd := range commands {
+		m.Commands = append(m.Commands, CommandInfo{Path: cmd.Path, Brief: cmd.Brief, Alias: cmd.Alias, Hidden: cmd.hidden,
+			Group: cmd.Group, Aliases: cmd.Aliases})
+	}
+
+	if ctx.desc.parsed {
+		synopsis := ctx.name
+		if len(ctx.desc.flags) > 0 {
+			synopsis += " [options]"
+		}
+		for _, nf := range ctx.desc.nonflags {
+			info := nf.info()
+			name := info.Placeholder
+			if info.Variadic {
+				name += "..."
+			}
+			if info.Required {
+				synopsis += " <" + name + ">"
+			} else {
+				synopsis += " [" + name + "]"
+			}
+			m.Args = append(m.Args, info)
+		}
+		m.Synopsis = synopsis
+
+		// the predefined flags are general ones if the flags are grouped
+		var grouped bool
+		for _, f := range ctx.desc.flags {
+			grouped = grouped || f.group != ""
+		}
+		flags := append(append([]*flag{}, ctx.desc.flags...), ctx.desc.predefined...)
+		for i, f := range flags {
+			info := f.info()
+			if grouped && i >= len(ctx.desc.flags) {
+				info.Group = "General"
+			}
+			info.DefaultText = defaultText(f)
+			if !c.showDeprecated {
+				info.Deprecated = nil
+			}
+			m.Flags = append(m.Flags, info)
+		}
+	}
+
+	if ctx.desc.synopsis != "" {
+		m.Synopsis = ctx.desc.synopsis
+	} else if cmd := c.commands.get(ctx.name); cmd != nil && cmd.synopsis != "" {
+		m.Synopsis = cmd.synopsis
+	}
+	m.Examples, m.Footer = ctx.desc.examples, ctx.desc.footer
+	if cmd := c.commands.get(ctx.name); cmd != nil {
+		if len(m.Examples) == 0 {
+			m.Examples = cmd.examples
+		}
+		if m.Footer == "" {
+			m.Footer = cmd.footer
+		}
+	}
+	return m
+}
+
+// defaultText returns the default value shown in the usage, empty for the flags
+// which take no value or are required
+func defaultText(f *flag) string {
+	if f.required || f.kind() == reflect.Bool {
+		return ""
+	}
+	// the provider is invoked only if there is no static placeholder
+	if f.defaultValue == "" && f.defaultFunc != nil {
+		if value, err := f.defaultFunc(stdctx.Background()); err == nil {
+			return value
+		}
+		return ""
+	}
+	if f.defaultValue == "" {
+		// a pointer stays nil unless the flag is set
+		if f.rv.Kind() == reflect.Ptr && f.rv.IsNil() {
+			return "nil"
+		}
+		if f.rv.CanAddr() && f.rv.Addr().Type().Implements(valueType) {
+			return f.rv.Addr().Interface().(Value).String()
+		}
+		if f.isFileMode(f.rv.Type()) {
+			return fmt.Sprintf("%#o", f.rv.Uint())
+		}
+		// the types like big.Int render themselves with a pointer receiver
+		if f.rv.CanAddr() && f.rv.Addr().Type().Implements(stringerType) {
+			return f.rv.Addr().Interface().(fmt.Stringer).String()
+		}
+		// a []byte is shown in the encoded form as it is typed
+		if isBytes(f.rv.Type()) {
+			return fmt.Sprintf("%q", encodeBytes(f.rv.Bytes(), f.encoding))
+		}
+		// a json value is shown as it is typed
+		if f.format == "json" {
+			if data, err := json.Marshal(f.rv.Interface()); err == nil {
+				return string(data)
+			}
+		}
+		// if no default value, use its zero value
+		if f.rv.Kind() == reflect.String {
+			return fmt.Sprintf("%q", f.rv.Interface())
+		}
+		return fmt.Sprintf("%v", f.rv.Interface())
+	}
+	// a file mode is rendered in octal, like 0644
+	if f.isFileMode(f.rv.Type()) {
+		if mode, err := parseFileMode(f.defaultValue, false); err == nil {
+			return fmt.Sprintf("%#o", mode)
+		}
+	}
+	// a Value renders the default in its canonical form, the default is applied to a
+	// scratch value so the field is left untouched
+	if reflect.PtrTo(f.rv.Type()).Implements(valueType) {
+		scratch := reflect.New(f.rv.Type())
+		if err := applyDefault(f, scratch.Elem()); err == nil {
+			return scratch.Interface().(Value).String()
+		}
+	}
+	// a size is rendered in the human form, like 4MiB for 4194304
+	if size, err := parseByteSize(f.defaultValue); err == nil && f.rv.Type() == reflect.TypeOf(ByteSize(0)) {
+		return ByteSize(size).String()
+	}
+	return f.defaultValue
+}
+
+// renderUsage renders the usage model as text
+func renderUsage(m UsageModel) string {
+	out := bytes.NewBuffer(nil)
+	if m.Title != "" {
+		out.WriteString(m.Title + "\n\n")
+	}
+	if m.Description != "" {
+		out.WriteString(m.Description + "\n\n")
+	}
+
+	//  print the aliailable commands, the ungrouped ones first and then the groups
+	cmds := bytes.NewBuffer(nil)
+	alias := bytes.NewBuffer(nil)
+	var groups []string
+	grouped := make(map[string]*bytes.Buffer)
+	for _, cmd := range m.Commands {
+		if cmd.Hidden {
+			continue
+		}
+		writeString := cmds.WriteString
+		if cmd.Alias {
+			writeString = alias.WriteString
+		} else if cmd.Group != "" {
+			if grouped[cmd.Group] == nil {
+				groups = append(groups, cmd.Group)
+				grouped[cmd.Group] = bytes.NewBuffer(nil)
+			}
+			writeString = grouped[cmd.Group].WriteString
+		}
+		path := cmd.Path
+		if len(cmd.Aliases) > 0 {
+			path += " (" + strings.Join(cmd.Aliases, ", ") + ")"
+		}
+		writeString(fmt.Sprintf("%-30s%s\n", path, cmd.Brief))
+	}
+	if cmds.Len() > 0 || alias.Len() > 0 || len(groups) > 0 {
+		if cmds.Len() > 0 || len(groups) == 0 {
+			out.WriteString("Available commands:\n\n")
+			out.WriteString(cmds.String() + "\n\n")
+		}
+		for _, group := range groups {
+			out.WriteString(group + ":\n\n")
+			out.WriteString(grouped[group].String() + "\n\n")
+		}
+		if alias.Len() > 0 {
+			out.WriteString("Alias commands:\n\n")
+			out.WriteString(alias.String() + "\n")
+		}
+	}
+
+	if m.Synopsis != "" {
+		out.WriteString(flagsUsage(m))
+	}
+	if len(m.Examples) > 0 {
+		out.WriteString("Examples:\n\n")
+		for _, example := range m.Examples {
+			out.WriteString("  " + example + "\n")
+		}
+		out.WriteString("\n")
+	}
+	if m.Footer != "" {
+		out.WriteString(m.Footer + "\n")
+	}
+	return out.String()
+}
+
+// flagsUsage renders the synopsis and the flags
+func flagsUsage(m UsageModel) string {
+	out := bytes.NewBuffer(nil)
+	out.WriteString("Usage:" + m.Synopsis + "\n")
+	if len(m.Flags) > 0 || len(m.Args) > 0 {
+		// the ungrouped flags come first, then the groups in the order of declaration
+		var groups []string
+		grouped := make(map[string][]FlagInfo)
+		for _, f := range m.Flags {
+			if _, ok := grouped[f.Group]; !ok && f.Group != "" {
+				groups = append(groups, f.Group)
+			}
+			grouped[f.Group] = append(grouped[f.Group], f)
+		}
+		out.WriteString("\n")
+		out.WriteString(flagLines(grouped[""]))
+		for _, group := range groups {
+			out.WriteString("\n" + group + " options:\n\n")
+			out.WriteString(flagLines(grouped[group]))
+		}
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+// flagLines renders a line for each of the flags
+func flagLines(flags []FlagInfo) string {
+	w := bytes.NewBuffer(nil)
+	for _, f := range flags {
+		var flag string
+		if f.Short != "-" && f.Short != "" {
+			flag += f.Short
+		}
+		if f.Long != "-" {
+			if f.Short != "-" && f.Short != "" {
+				flag += ", " + f.Long
+			} else {
+				flag += "    " + f.Long
+			}
+		}
+		if f.Placeholder != "" {
+			if f.OptionalValue {
+				flag += "[=" + f.Placeholder + "]"
+			} else {
+				flag += " " + f.Placeholder
+			}
+		}
+		if len(flag) > 30 {
+			// align with 32 spaces
+			flag += "\n                                "
+		}
+		description := f.Description + requirementHint(f.RequiredIf, f.RequiredUnless)
+		if len(f.Choices) > 0 {
+			description += " (one of " + strings.Join(f.Choices, ", ") + ")"
+		}
+		if len(f.Aliases) > 0 {
+			description += " (also " + strings.Join(f.Aliases, ", ") + ")"
+		}
+		if f.Env != "" {
+			description += " [env: " + f.Env + "]"
+		}
+		if len(f.Excludes) > 0 {
+			description += " (not with " + strings.Join(f.Excludes, ", ") + ")"
+		}
+		if len(f.Deprecated) > 0 {
+			description += " (deprecated: " + strings.Join(f.Deprecated, ", ") + ")"
+		}
+		// a flag which is on by default is only turned off by its negation
+		if on, _ := parseBool(f.Default); f.Negation != "" && on {
+			description += " (" + f.Negation + " to disable)"
+		}
+		s := wordWrapWithPrefix(fmt.Sprintf("  %-30s ", flag), description, 50, 33) // 30+ 3 spaces
+		// the bounds of a number are shown next to the default
+		var hints []string
+		if !f.Required && f.Placeholder != "" {
+			hints = append(hints, "default="+f.DefaultText)
+		}
+		if f.Min != "" {
+			hints = append(hints, "min="+f.Min)
+		}
+		if f.Max != "" {
+			hints = append(hints, "max="+f.Max)
+		}
+		if len(hints) > 0 {
+			w.WriteString(s + "(" + strings.Join(hints, ", ") + ")\n")
+		} else {
+			w.WriteString(s + "\n")
+		}
+	}
+	return w.String()
+}
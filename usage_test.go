@@ -0,0 +1,45 @@
+package cortana
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUsageExamplesAndFooter(t *testing.T) {
+	type options struct {
+		Output string `cortana:"--output, -o, text, output format"`
+	}
+	c := New(ExitOnError(false), WithStdout(io.Discard), WithStderr(io.Discard))
+	var usage string
+	var m UsageModel
+	c.AddCommand("get", func() {
+		c.Examples("mytool get pods -o json")
+		c.Parse(&options{}, OnUsage(func(s string) {
+			usage, m = s, c.UsageModel()
+		}))
+	}, "get resources", WithFlags(&options{}), WithExamples("mytool get pods"), WithFooter("See https://example.com/docs"))
+
+	// the registered examples and footer without running the command
+	model, err := c.UsageModelOf("get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(model.Examples, []string{"mytool get pods"}) || model.Footer != "See https://example.com/docs" {
+		t.Errorf("unexpected examples %q and footer %q", model.Examples, model.Footer)
+	}
+	text, _ := c.UsageOf("get")
+	if !strings.HasSuffix(text, "Examples:\n\n  mytool get pods\n\nSee https://example.com/docs\n") {
+		t.Errorf("expected the examples and the footer at the end, got %q", text)
+	}
+
+	// the examples set by the command override the registered ones
+	c.Launch("get", "--help")
+	if !reflect.DeepEqual(m.Examples, []string{"mytool get pods -o json"}) || m.Footer != "See https://example.com/docs" {
+		t.Errorf("unexpected examples %q and footer %q", m.Examples, m.Footer)
+	}
+	if !strings.Contains(usage, "  mytool get pods -o json\n") || strings.Contains(usage, "  mytool get pods\n") {
+		t.Errorf("unexpected examples in the usage %q", usage)
+	}
+}
@@ -0,0 +1,218 @@
+package cortana
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// checkConstraints checks the constraints declared by the tags and compiles the
+// patterns, so the mistakes of the tags are reported before any value is parsed
+func (c *Cortana) checkConstraints() error {
+	for _, f := range c.parsingFlags() {
+		if err := f.compilePattern(); err != nil {
+			return fmt.Errorf("cortana: field %s: %v", f.path, err)
+		}
+		if err := f.parseBounds(); err != nil {
+			return fmt.Errorf("cortana: field %s: %v", f.path, err)
+		}
+		if _, ok := repeatPolicies[f.repeat]; f.repeat != "" && !ok {
+			return fmt.Errorf("cortana: field %s: invalid repeat %q, should be first, last or error", f.path, f.repeat)
+		}
+		if f.rest && (strings.HasPrefix(f.long, "-") || !isList(f.rv)) {
+			return fmt.Errorf("cortana: field %s: rest only applies to a positional list", f.path)
+		}
+		if f.mode != "" && f.mode != "r" && f.mode != "w" {
+			return fmt.Errorf("cortana: field %s: invalid mode %q, should be r or w", f.path, f.mode)
+		}
+		if f.encoding != "" && f.encoding != "base64" && f.encoding != "hex" {
+			return fmt.Errorf("cortana: field %s: invalid encoding %q, should be base64 or hex", f.path, f.encoding)
+		}
+		if f.format != "" && f.format != "json" && f.format != "filemode" {
+			return fmt.Errorf("cortana: field %s: invalid format %q, should be json or filemode", f.path, f.format)
+		}
+		if f.format == "filemode" && !f.isFileMode(f.rv.Type()) {
+			return fmt.Errorf("cortana: field %s: format filemode requires an unsigned integer", f.path)
+		}
+		// an out of range default is a mistake of the program rather than the user
+		if err := f.checkDefaultRange(); err != nil {
+			panic(fmt.Sprintf("cortana: field %s: %v", f.path, err))
+		}
+	}
+	return nil
+}
+
+// parseBounds parses the min and max tags as the values of the element type
+func (f *flag) parseBounds() error {
+	if f.min == "" && f.max == "" {
+		return nil
+	}
+	rt := f.rv.Type()
+	for rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if isList(reflect.New(rt).Elem()) {
+		rt = rt.Elem()
+	}
+	switch rt.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return fmt.Errorf("min and max only apply to the numbers, not %s", rt)
+	}
+	for _, bound := range []struct {
+		text  string
+		value *reflect.Value
+	}{{f.min, &f.minValue}, {f.max, &f.maxValue}} {
+		if bound.text == "" {
+			continue
+		}
+		v := reflect.New(rt).Elem()
+		if err := applyValue(f, v, bound.text); err != nil {
+			return fmt.Errorf("invalid bound %q: %v", bound.text, err)
+		}
+		*bound.value = v
+	}
+	return nil
+}
+
+// checkDefaultRange checks if the default value in the tag is in the range
+func (f *flag) checkDefaultRange() error {
+	if !f.minValue.IsValid() && !f.maxValue.IsValid() {
+		return nil
+	}
+	if f.defaultValue == "" || f.defaultFunc != nil || (f.rv.Kind() == reflect.Slice && f.defaultValue == "nil") {
+		return nil
+	}
+	v := reflect.New(f.rv.Type()).Elem()
+	if err := applyDefault(f, v); err != nil {
+		return nil // reported when the default value is applied
+	}
+	for _, e := range elemValues(v) {
+		if err := f.checkRange(e); err != nil {
+			return fmt.Errorf("invalid default value: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkRange checks if the number is between the bounds
+func (f *flag) checkRange(v reflect.Value) error {
+	if (!f.minValue.IsValid() || compareNumbers(v, f.minValue) >= 0) &&
+		(!f.maxValue.IsValid() || compareNumbers(v, f.maxValue) <= 0) {
+		return nil
+	}
+	switch {
+	case f.min != "" && f.max != "":
+		return fmt.Errorf("%s must be between %s and %s, got %v", f.displayName(), f.min, f.max, v.Interface())
+	case f.min != "":
+		return fmt.Errorf("%s must be at least %s, got %v", f.displayName(), f.min, v.Interface())
+	}
+	return fmt.Errorf("%s must be at most %s, got %v", f.displayName(), f.max, v.Interface())
+}
+
+// compareNumbers compares the numbers of the same kind, it returns -1, 0 or 1
+func compareNumbers(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, y := a.Int(), b.Int()
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, y := a.Uint(), b.Uint()
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// compilePattern compiles the pattern, which matches the whole value
+func (f *flag) compilePattern() error {
+	if f.pattern == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(f.pattern); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", f.pattern, err)
+	}
+	f.re = regexp.MustCompile("^(?:" + f.pattern + ")$")
+	return nil
+}
+
+// validateValues validates the final values of the flags against the constraints
+// declared by the tags, whichever source the values come from. The values which
+// are neither set nor changed from the zero value are skipped
+func (c *Cortana) validateValues() error {
+	for _, f := range c.parsingFlags() {
+		if !f.isSet() && (!f.rv.IsValid() || f.rv.IsZero()) {
+			continue
+		}
+		for _, v := range elemValues(f.rv) {
+			if err := f.validate(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validate validates a single value of the flag
+func (f *flag) validate(v reflect.Value) error {
+	s := fmt.Sprint(v.Interface())
+	if len(f.choices) > 0 && !containsString(f.choices, s) {
+		return fmt.Errorf("invalid value %q for %s, should be one of %s", s, f.displayName(), strings.Join(f.choices, ", "))
+	}
+	if f.re != nil && !f.re.MatchString(s) {
+		return fmt.Errorf("invalid value %q for %s, should match the pattern %s", s, f.displayName(), f.pattern)
+	}
+	if err := f.checkRange(v); err != nil {
+		return err
+	}
+	return nil
+}
+
+// elemValues returns the elements of a list, or the value itself if it is a single
+// one, the nil pointers are skipped
+func elemValues(rv reflect.Value) []reflect.Value {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !isList(rv) {
+		return []reflect.Value{rv}
+	}
+	values := make([]reflect.Value, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		values = append(values, rv.Index(i))
+	}
+	return values
+}
+
+// completeChoices completes the value with the choices
+func completeChoices(choices []string) func(prefix string) []string {
+	return func(prefix string) []string {
+		var matches []string
+		for _, choice := range choices {
+			if strings.HasPrefix(choice, prefix) {
+				matches = append(matches, choice)
+			}
+		}
+		return matches
+	}
+}